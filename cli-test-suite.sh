@@ -35,7 +35,42 @@ checkssl [url] [url] [url] ...
   -no-output (will only produce exit code)
   -no-header (will disable the header row in CSV output)
   -short (will show only 1 line per result)
+  -verbose (will show every detail of each certificate)
   -timeout=5 (will set the timeout to 5 seconds)  default = 15
+  -raw-tls (will only do a TLS handshake, for non-HTTP services like ldaps or imaps)
+  -concurrency=10 (will check up to 10 targets at the same time)
+  -stream (will show results as they finish instead of in the order given)
+  -all-ips (will check every A and AAAA record of the host on its own)
+  -resolve=example.com:443:10.0.0.5 (will connect to 10.0.0.5 but still check the cert for example.com)
+  -connect-to=example.com:443:lb.example.net:8443 (will connect to lb.example.net:8443 instead)
+  -proxy=socks5://127.0.0.1:1080 (will connect through an http:// or socks5:// proxy)
+  -no-proxy (will ignore the HTTPS_PROXY and HTTP_PROXY environment variables)
+  -max-redirects=3 (will follow up to 3 redirects, 0 to not follow any)  default = 10
+  -ocsp (will ask the OCSP responder if the certificate has been revoked)
+  -ocsp-responder=http://ocsp.example.com (will send OCSP requests to this responder instead)
+  -crl (will check each certificate against the CRLs it lists)
+  -crl-file=ca.crl (will use this CRL instead of downloading one, can be repeated)
+  -crl-cache=/tmp/crls (will keep downloaded CRLs in this folder until they expire)
+  -ct (will check the certificate has enough valid SCTs for Chrome and Apple)
+  -ct-logs=log_list.json (will use this CT log list instead of the bundled one)
+  -aia (will download intermediates the server did not send from the CA Issuers url, to show which are missing)
+  -ca-file=ca.pem (will trust the CA certificates in this PEM file instead of the system roots)
+  -ca-dir=/etc/ssl/private-ca (will trust the CA certificates in every PEM file in this folder)
+  -ca-append (will add the -ca-file and -ca-dir certificates to the system roots instead of replacing them)
+  -client-cert=client.pem (will send this client certificate when the server asks for one, PEM or PKCS#12)
+  -client-key=client.key (will use this PEM private key with -client-cert)
+  -client-password=secret (will use this password to open a PKCS#12 -client-cert)
+  -min-rsa=2048 (will fail certificates with an RSA key smaller than this many bits)
+  -min-ec=256 (will fail certificates with an EC key smaller than this many bits)
+  -tls-versions (will try a handshake at every TLS version and list the ones the server accepts)
+  -min-tls=1.2 (will fail if the server accepts a TLS version below this one, turns on -tls-versions)
+  -ciphers (will try each cipher suite at every TLS version and list the ones the server accepts, turns on -tls-versions)
+  -legacy (will send hand built ClientHellos for SSL v3.0 through TLS v1.2 with the insecure cipher suites Go can not offer, and list what the server accepts)
+  -simulate (will connect as a set of common clients, like old Android, Java 8, OpenSSL and current browsers, and show what each one negotiates)
+  -client-profiles=clients.json (will add the client profiles in this file to the built in ones, turns on -simulate)
+  -hsts (will check the Strict-Transport-Security header, that http:// redirects to https:// and if the host is on the HSTS preload list)
+  -hsts-min-age=31536000 (will fail if the HSTS header is missing or its max-age is below this many seconds, turns on -hsts)
+  -hsts-preload=preload.json (will use this HSTS preload list instead of the bundled one, turns on -hsts)
 END
 )
 diff <(echo "$OUTPUT") <(echo "$EXPECTED") && passtest "blank input matches" || failtest "blank input does not match"
@@ -1,3 +1,7 @@
 module github.com/szazeski/checkssl
 
-go 1.17
+go 1.25
+
+require software.sslmate.com/src/go-pkcs12 v0.7.3
+
+require golang.org/x/crypto v0.11.0 // indirect
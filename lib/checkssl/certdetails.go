@@ -0,0 +1,169 @@
+package checkssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+var (
+	keyUsageNames = []struct {
+		usage x509.KeyUsage
+		name  string
+	}{
+		{x509.KeyUsageDigitalSignature, "Digital Signature"},
+		{x509.KeyUsageContentCommitment, "Content Commitment"},
+		{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+		{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+		{x509.KeyUsageKeyAgreement, "Key Agreement"},
+		{x509.KeyUsageCertSign, "Certificate Sign"},
+		{x509.KeyUsageCRLSign, "CRL Sign"},
+		{x509.KeyUsageEncipherOnly, "Encipher Only"},
+		{x509.KeyUsageDecipherOnly, "Decipher Only"},
+	}
+
+	extKeyUsageNames = map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageAny:                            "Any",
+		x509.ExtKeyUsageServerAuth:                     "Server Auth",
+		x509.ExtKeyUsageClientAuth:                     "Client Auth",
+		x509.ExtKeyUsageCodeSigning:                    "Code Signing",
+		x509.ExtKeyUsageEmailProtection:                "Email Protection",
+		x509.ExtKeyUsageIPSECEndSystem:                 "IPSec End System",
+		x509.ExtKeyUsageIPSECTunnel:                    "IPSec Tunnel",
+		x509.ExtKeyUsageIPSECUser:                      "IPSec User",
+		x509.ExtKeyUsageTimeStamping:                   "Time Stamping",
+		x509.ExtKeyUsageOCSPSigning:                    "OCSP Signing",
+		x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "Microsoft Server Gated Crypto",
+		x509.ExtKeyUsageNetscapeServerGatedCrypto:      "Netscape Server Gated Crypto",
+		x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "Microsoft Commercial Code Signing",
+		x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "Microsoft Kernel Code Signing",
+	}
+)
+
+// setDetails copies everything else worth knowing about cert into a.
+func (a *CheckCert) setDetails(cert *x509.Certificate) {
+	a.Subject = cert.Subject.String()
+	a.Issuer = cert.Issuer.String()
+	a.SerialNumber = colonHex(cert.SerialNumber.Bytes())
+
+	a.DnsNames = cert.DNSNames
+	for _, ip := range cert.IPAddresses {
+		a.IpAddresses = append(a.IpAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		a.Uris = append(a.Uris, uri.String())
+	}
+	a.EmailAddresses = cert.EmailAddresses
+
+	fingerprint := sha256.Sum256(cert.Raw)
+	a.Sha256Fingerprint = colonHex(fingerprint[:])
+	spkiFingerprint := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	a.SpkiSha256Fingerprint = colonHex(spkiFingerprint[:])
+
+	a.PublicKeyAlgorithm = cert.PublicKeyAlgorithm.String()
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		a.PublicKeySize = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		a.PublicKeySize = key.Curve.Params().BitSize
+		a.PublicKeyCurve = key.Curve.Params().Name
+	case ed25519.PublicKey:
+		a.PublicKeySize = 256
+		a.PublicKeyCurve = "Ed25519"
+	}
+	a.SignatureAlgorithm = cert.SignatureAlgorithm.String()
+
+	for _, usage := range keyUsageNames {
+		if cert.KeyUsage&usage.usage != 0 {
+			a.KeyUsage = append(a.KeyUsage, usage.name)
+		}
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		name, found := extKeyUsageNames[usage]
+		if !found {
+			name = fmt.Sprintf("Unknown (%d)", usage)
+		}
+		a.ExtKeyUsage = append(a.ExtKeyUsage, name)
+	}
+	for _, usage := range cert.UnknownExtKeyUsage {
+		a.ExtKeyUsage = append(a.ExtKeyUsage, usage.String())
+	}
+
+	a.OcspServers = cert.OCSPServer
+	a.IssuingCertificateUrls = cert.IssuingCertificateURL
+	a.CrlDistributionPoints = cert.CRLDistributionPoints
+	for _, policy := range cert.Policies {
+		a.PolicyOids = append(a.PolicyOids, policy.String())
+	}
+}
+
+// colonHex formats bytes the way openssl shows fingerprints, e.g. 0A:1B:2C
+func colonHex(input []byte) string {
+	output := make([]string, len(input))
+	for i, b := range input {
+		output[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(output, ":")
+}
+
+// sans lists every subject alternative name with its type, e.g. DNS:example.com
+func (a CheckCert) sans() (output []string) {
+	for _, name := range a.DnsNames {
+		output = append(output, "DNS:"+name)
+	}
+	for _, ip := range a.IpAddresses {
+		output = append(output, "IP:"+ip)
+	}
+	for _, uri := range a.Uris {
+		output = append(output, "URI:"+uri)
+	}
+	for _, email := range a.EmailAddresses {
+		output = append(output, "email:"+email)
+	}
+	return
+}
+
+func (a CheckCert) publicKeyDescription() string {
+	switch {
+	case a.PublicKeyCurve == "Ed25519":
+		return a.PublicKeyCurve
+	case a.PublicKeyCurve != "":
+		return fmt.Sprintf("%s %s", a.PublicKeyAlgorithm, a.PublicKeyCurve)
+	case a.PublicKeySize > 0:
+		return fmt.Sprintf("%s %d bits", a.PublicKeyAlgorithm, a.PublicKeySize)
+	}
+	return a.PublicKeyAlgorithm
+}
+
+// verboseDetails is the extra section under each certificate in verbose text output.
+func (a CheckCert) verboseDetails() (output string) {
+	lines := []struct {
+		label string
+		value string
+	}{
+		{"Subject", a.Subject},
+		{"Issuer", a.Issuer},
+		{"Serial", a.SerialNumber},
+		{"SANs", strings.Join(a.sans(), ", ")},
+		{"Key", a.publicKeyDescription()},
+		{"Signature", a.SignatureAlgorithm},
+		{"Key Usage", strings.Join(a.KeyUsage, ", ")},
+		{"Extended Key Usage", strings.Join(a.ExtKeyUsage, ", ")},
+		{"OCSP", strings.Join(a.OcspServers, ", ")},
+		{"CA Issuers", strings.Join(a.IssuingCertificateUrls, ", ")},
+		{"CRL", strings.Join(a.CrlDistributionPoints, ", ")},
+		{"Policies", strings.Join(a.PolicyOids, ", ")},
+		{"SHA-256", a.Sha256Fingerprint},
+		{"SPKI SHA-256", a.SpkiSha256Fingerprint},
+	}
+	for _, line := range lines {
+		if line.value != "" {
+			output += fmt.Sprintf("      %s: %s\n", line.label, line.value)
+		}
+	}
+	return
+}
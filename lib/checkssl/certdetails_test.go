@@ -0,0 +1,120 @@
+package checkssl
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_setDetails(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	policy, err := x509.OIDFromInts([]uint64{2, 23, 140, 1, 2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, _ := url.Parse("spiffe://example.com/web")
+	leaf := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(0x0a1b2c),
+		Subject:               pkix.Name{CommonName: "example.com", Organization: []string{"Example Inc"}},
+		DNSNames:              []string{"example.com", "www.example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		URIs:                  []*url.URL{uri},
+		EmailAddresses:        []string{"admin@example.com"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		OCSPServer:            []string{"http://ocsp.example.com"},
+		IssuingCertificateURL: []string{"http://ca.example.com/ca.crt"},
+		CRLDistributionPoints: []string{"http://crl.example.com/ca.crl"},
+		Policies:              []x509.OID{policy},
+	}, ca)
+
+	actual := CheckCert{}
+	actual.setDetails(leaf.Cert)
+
+	assert(t, actual.Subject, "CN=example.com,O=Example Inc", "")
+	assert(t, actual.Issuer, "CN=Test CA", "")
+	assert(t, actual.SerialNumber, "0A:1B:2C", "")
+	assert(t, strings.Join(actual.sans(), " "), "DNS:example.com DNS:www.example.com IP:127.0.0.1 URI:spiffe://example.com/web email:admin@example.com", "")
+	assert(t, actual.publicKeyDescription(), "ECDSA P-256", "")
+	assert(t, actual.SignatureAlgorithm, "ECDSA-SHA256", "")
+	assert(t, strings.Join(actual.KeyUsage, ", "), "Digital Signature, Key Encipherment", "")
+	assert(t, strings.Join(actual.ExtKeyUsage, ", "), "Server Auth, Client Auth", "")
+	assert(t, strings.Join(actual.OcspServers, " "), "http://ocsp.example.com", "")
+	assert(t, strings.Join(actual.IssuingCertificateUrls, " "), "http://ca.example.com/ca.crt", "")
+	assert(t, strings.Join(actual.CrlDistributionPoints, " "), "http://crl.example.com/ca.crl", "")
+	assert(t, strings.Join(actual.PolicyOids, " "), "2.23.140.1.2.1", "")
+
+	fingerprint := sha256.Sum256(leaf.Cert.Raw)
+	assert(t, actual.Sha256Fingerprint, colonHex(fingerprint[:]), "")
+	spkiFingerprint := sha256.Sum256(leaf.Cert.RawSubjectPublicKeyInfo)
+	assert(t, actual.SpkiSha256Fingerprint, colonHex(spkiFingerprint[:]), "")
+}
+
+func Test_setDetails_rsa(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rsa.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	actual := CheckCert{}
+	actual.setDetails(cert)
+	assert(t, actual.publicKeyDescription(), "RSA 2048 bits", "")
+	assert(t, actual.SignatureAlgorithm, "SHA256-RSA", "")
+}
+
+func Test_colonHex(t *testing.T) {
+	assert(t, colonHex([]byte{0x00, 0xab, 0x10}), "00:AB:10", "")
+	assert(t, colonHex(nil), "", "")
+}
+
+func Test_CheckServer_certDetails(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost", "www.localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate(ca)}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	assert(t, strings.Join(actual.Certs[0].DnsNames, " "), "localhost www.localhost", "")
+	assert(t, actual.Certs[1].Subject, "CN=Test CA", "")
+	if !strings.Contains(actual.AsJson(), `"DnsNames":["localhost","www.localhost"]`) {
+		t.Error("expected the SANs in JSON output", actual.AsJson())
+	}
+
+	if strings.Contains(actual.AsString(false), "SANs:") {
+		t.Error("details should only be shown in verbose output")
+	}
+	verbose := actual.AsVerboseString(false)
+	for _, expected := range []string{
+		"      SANs: DNS:localhost, DNS:www.localhost, IP:127.0.0.1\n",
+		"      Issuer: CN=Test CA\n",
+		"      Key: ECDSA P-256\n",
+		"      Extended Key Usage: Server Auth\n",
+		"      SHA-256: " + actual.Certs[0].Sha256Fingerprint + "\n",
+	} {
+		if !strings.Contains(verbose, expected) {
+			t.Error("expected verbose output to include", expected, verbose)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package checkssl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	CHAIN_ERROR   = "error"
+	CHAIN_WARNING = "warning"
+
+	aiaMaxFetches = 4
+)
+
+// ChainAnalysis describes problems with the certificates the server sent,
+// which browsers often work around but curl, Java and other clients do not.
+type ChainAnalysis struct {
+	Complete bool
+	Ordered  bool
+	Findings []ChainFinding `json:",omitempty"`
+	Fetched  []CheckCert    `json:",omitempty"`
+}
+
+// ChainFinding is a problem with the certificate at Position, counting from 1
+// like the text output.
+type ChainFinding struct {
+	Position int
+	Severity string
+	Message  string
+}
+
+// SetFetchMissingIntermediates downloads intermediates the server did not
+// send from the CA Issuers url in the certificate, to show which ones are
+// missing. The check still fails, since most clients will not do this.
+func (a *CheckSSL) SetFetchMissingIntermediates(enable bool) {
+	a.fetchIntermediates = enable
+}
+
+func (a *ChainAnalysis) add(position int, severity string, message string) {
+	a.Findings = append(a.Findings, ChainFinding{Position: position, Severity: severity, Message: message})
+}
+
+// failed returns the first error found, if any.
+func (a *ChainAnalysis) failed() string {
+	if a == nil {
+		return ""
+	}
+	for _, finding := range a.Findings {
+		if finding.Severity == CHAIN_ERROR {
+			return fmt.Sprintf("certificate %d %s", finding.Position, finding.Message)
+		}
+	}
+	return ""
+}
+
+// analyzeChain follows the issuers from the leaf through the certificates
+// the server sent, in the order it sent them.
+func (a *CheckSSL) analyzeChain(chain []*x509.Certificate) *ChainAnalysis {
+	if len(chain) == 0 {
+		return nil
+	}
+	output := &ChainAnalysis{Complete: true, Ordered: true}
+
+	duplicates := map[int]bool{}
+	firstSeen := map[[sha256.Size]byte]int{}
+	for i, cert := range chain {
+		hash := sha256.Sum256(cert.Raw)
+		if first, found := firstSeen[hash]; found {
+			duplicates[i] = true
+			output.add(i+1, CHAIN_WARNING, fmt.Sprintf("is a duplicate of certificate %d", first+1))
+			continue
+		}
+		firstSeen[hash] = i
+	}
+
+	path := []int{0}
+	used := map[int]bool{0: true}
+	for !isSelfSigned(chain[path[len(path)-1]]) {
+		current := path[len(path)-1]
+		expected := current + 1
+		for expected < len(chain) && duplicates[expected] {
+			expected++
+		}
+		next := -1
+		for i := range chain {
+			if !used[i] && !duplicates[i] && isIssuedBy(chain[current], chain[i]) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			// the issuer was not sent, anything after current is unrelated to it
+			break
+		}
+		if expected < len(chain) && !isIssuedBy(chain[current], chain[expected]) {
+			output.add(current+1, CHAIN_ERROR, issuerMismatch(chain[current], chain[expected], expected+1))
+		}
+		if next != expected {
+			output.Ordered = false
+			output.add(next+1, CHAIN_ERROR, fmt.Sprintf("issued certificate %d so it should be sent right after it", current+1))
+		}
+		path = append(path, next)
+		used[next] = true
+	}
+
+	for i := range chain {
+		if !used[i] && !duplicates[i] {
+			output.add(i+1, CHAIN_WARNING, "is not part of the chain from the leaf and does not need to be sent")
+		}
+	}
+
+	last := path[len(path)-1]
+	if len(path) > 1 && isSelfSigned(chain[last]) {
+		output.add(last+1, CHAIN_WARNING, "is a self signed root, which clients already have and does not need to be sent")
+	}
+	if !isSelfSigned(chain[last]) && !a.isIssuedByTrustedRoot(chain[last]) {
+		output.Complete = false
+		a.findMissingIntermediates(output, chain[last], last+1)
+	}
+	return output
+}
+
+// findMissingIntermediates reports the issuer that was not sent, and when
+// enabled downloads it and any others above it.
+func (a *CheckSSL) findMissingIntermediates(output *ChainAnalysis, cert *x509.Certificate, position int) {
+	message := fmt.Sprintf("was issued by %s, which was not sent", cert.Issuer)
+	if !a.fetchIntermediates {
+		output.add(position, CHAIN_ERROR, message)
+		return
+	}
+
+	for i := 0; i < aiaMaxFetches && !isSelfSigned(cert) && !a.isIssuedByTrustedRoot(cert); i++ {
+		issuer, source, err := a.fetchIssuer(cert)
+		if err != nil {
+			output.add(position, CHAIN_ERROR, message+", and it could not be downloaded: "+err.Error())
+			return
+		}
+		output.add(position, CHAIN_ERROR, message+", it can be downloaded from "+source)
+
+		details := CheckCert{CommonName: issuer.Subject.CommonName, IsCertificateAuthority: issuer.IsCA, ValidNotBefore: issuer.NotBefore, ValidNotAfter: issuer.NotAfter}
+		details.setDetails(issuer)
+		output.Fetched = append(output.Fetched, details)
+
+		cert = issuer
+		message = fmt.Sprintf("was issued by %s, which was not sent either", cert.Issuer)
+	}
+}
+
+// fetchIssuer downloads the certificate from the CA Issuers url in cert.
+func (a *CheckSSL) fetchIssuer(cert *x509.Certificate) (*x509.Certificate, string, error) {
+	var lastErr error = errors.New("certificate has no CA Issuers url")
+	for _, source := range cert.IssuingCertificateURL {
+		if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+			continue
+		}
+		data, err := a.fetch(http.MethodGet, source, "", nil, ocspMaxResponseBytes)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if block, _ := pem.Decode(data); block != nil {
+			data = block.Bytes
+		}
+		issuer, err := x509.ParseCertificate(data)
+		if err != nil {
+			lastErr = fmt.Errorf("%s is not a DER or PEM certificate: %w", source, err)
+			continue
+		}
+		if !isIssuedBy(cert, issuer) {
+			lastErr = fmt.Errorf("%s is not the issuer", source)
+			continue
+		}
+		return issuer, source, nil
+	}
+	return nil, "", lastErr
+}
+
+// isSelfSigned does not use isIssuedBy, since a self signed leaf is not a CA
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// isIssuedBy checks the issuer name, the key identifiers when both are
+// present, and the signature.
+func isIssuedBy(cert *x509.Certificate, issuer *x509.Certificate) bool {
+	return issuerMismatch(cert, issuer, 0) == ""
+}
+
+func issuerMismatch(cert *x509.Certificate, issuer *x509.Certificate, issuerPosition int) string {
+	switch {
+	case !bytes.Equal(cert.RawIssuer, issuer.RawSubject):
+		return fmt.Sprintf("was issued by %s, not by certificate %d (%s)", cert.Issuer, issuerPosition, issuer.Subject)
+	case len(cert.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 && !bytes.Equal(cert.AuthorityKeyId, issuer.SubjectKeyId):
+		return fmt.Sprintf("has an authority key id that does not match the subject key id of certificate %d", issuerPosition)
+	case cert.CheckSignatureFrom(issuer) != nil:
+		return fmt.Sprintf("was not signed by the key of certificate %d", issuerPosition)
+	}
+	return ""
+}
+
+// isIssuedByTrustedRoot is true when the issuer of cert is in the trust store.
+// Only an unknown authority counts as missing, so an expired root or
+// intermediate is still reported by the other checks instead.
+func (a *CheckSSL) isIssuedByTrustedRoot(cert *x509.Certificate) bool {
+	_, err := cert.Verify(x509.VerifyOptions{Roots: a.rootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	var unknownAuthority x509.UnknownAuthorityError
+	return !errors.As(err, &unknownAuthority)
+}
+
+// findingsFor lists the findings about the certificate at position in text output.
+func (a *ChainAnalysis) findingsFor(position int, color terminalColors) (output string) {
+	if a == nil {
+		return ""
+	}
+	for _, finding := range a.Findings {
+		if finding.Position != position {
+			continue
+		}
+		findingColor := color.yellow
+		if finding.Severity == CHAIN_ERROR {
+			findingColor = color.red
+		}
+		output += fmt.Sprintf("      %s%s %s%s\n", findingColor, finding.Severity, finding.Message, color.noColor)
+	}
+	return
+}
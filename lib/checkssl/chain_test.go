@@ -0,0 +1,176 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestIntermediate(t *testing.T, root *testCertificate, commonName string, caIssuers ...string) *testCertificate {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		IssuingCertificateURL: caIssuers,
+	}, root)
+}
+
+func chainOf(certs ...*testCertificate) (output []*x509.Certificate) {
+	for _, cert := range certs {
+		output = append(output, cert.Cert)
+	}
+	return
+}
+
+func findingsOf(analysis *ChainAnalysis) (output []string) {
+	for _, finding := range analysis.Findings {
+		output = append(output, strings.Join([]string{string(rune('0' + finding.Position)), finding.Severity, finding.Message}, " "))
+	}
+	return
+}
+
+func Test_analyzeChain_complete(t *testing.T) {
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+
+	checker := NewCheckSSL()
+	actual := checker.analyzeChain(chainOf(leaf, intermediate, root))
+
+	if !actual.Complete || !actual.Ordered || actual.failed() != "" {
+		t.Fatal("expected a complete chain", findingsOf(actual))
+	}
+	assert(t, strings.Join(findingsOf(actual), "\n"), "3 warning is a self signed root, which clients already have and does not need to be sent", "")
+}
+
+func Test_analyzeChain_selfSignedLeaf(t *testing.T) {
+	leaf := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "localhost"}}, nil)
+
+	checker := NewCheckSSL()
+	actual := checker.analyzeChain(chainOf(leaf))
+
+	if !actual.Complete || len(actual.Findings) != 0 {
+		t.Error("a self signed leaf has nothing missing", findingsOf(actual))
+	}
+}
+
+func Test_analyzeChain_wrongOrder(t *testing.T) {
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+
+	checker := NewCheckSSL()
+	actual := checker.analyzeChain(chainOf(leaf, root, intermediate))
+
+	if actual.Ordered || !actual.Complete {
+		t.Fatal("expected the chain to be complete but out of order", findingsOf(actual))
+	}
+	assert(t, strings.Join(findingsOf(actual), "\n"), strings.Join([]string{
+		"1 error was issued by CN=Test Intermediate, not by certificate 2 (CN=Test Root)",
+		"3 error issued certificate 1 so it should be sent right after it",
+		"2 error issued certificate 3 so it should be sent right after it",
+		"2 warning is a self signed root, which clients already have and does not need to be sent",
+	}, "\n"), "")
+	assert(t, actual.failed(), "certificate 1 was issued by CN=Test Intermediate, not by certificate 2 (CN=Test Root)", "")
+}
+
+func Test_analyzeChain_missingIntermediate(t *testing.T) {
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+
+	checker := NewCheckSSL()
+	actual := checker.analyzeChain(chainOf(leaf, root))
+
+	if actual.Complete {
+		t.Fatal("expected the chain to be incomplete")
+	}
+	assert(t, strings.Join(findingsOf(actual), "\n"), strings.Join([]string{
+		"2 warning is not part of the chain from the leaf and does not need to be sent",
+		"1 error was issued by CN=Test Intermediate, which was not sent",
+	}, "\n"), "")
+}
+
+func Test_analyzeChain_leftoverCertificate(t *testing.T) {
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+	stale := newTestIntermediate(t, newTestCA(t, "Old Root"), "Old Intermediate")
+
+	checker := NewCheckSSL()
+	checker.rootCAs = x509.NewCertPool()
+	checker.rootCAs.AddCert(root.Cert)
+	actual := checker.analyzeChain(chainOf(leaf, intermediate, stale))
+
+	if !actual.Complete || !actual.Ordered || actual.failed() != "" {
+		t.Fatal("expected a complete chain with one certificate too many", findingsOf(actual))
+	}
+	assert(t, strings.Join(findingsOf(actual), "\n"), "3 warning is not part of the chain from the leaf and does not need to be sent", "")
+}
+
+func Test_analyzeChain_duplicate(t *testing.T) {
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+
+	checker := NewCheckSSL()
+	actual := checker.analyzeChain(chainOf(leaf, intermediate, intermediate, root))
+
+	if !actual.Complete || actual.failed() != "" {
+		t.Fatal("a duplicate should only be a warning", findingsOf(actual))
+	}
+	assert(t, findingsOf(actual)[0], "3 warning is a duplicate of certificate 2", "")
+}
+
+func Test_analyzeChain_fetchMissingIntermediates(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate", server.URL+"/root.crt")
+	leaf := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "localhost"},
+		IssuingCertificateURL: []string{server.URL + "/intermediate.crt"},
+	}, intermediate)
+	mux.HandleFunc("/intermediate.crt", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(intermediate.Cert.Raw) })
+	mux.HandleFunc("/root.crt", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(root.Cert.Raw) })
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetFetchMissingIntermediates(true)
+	actual := checker.analyzeChain(chainOf(leaf))
+
+	if actual.Complete || len(actual.Fetched) != 2 {
+		t.Fatal("expected both missing certificates to be downloaded", findingsOf(actual))
+	}
+	assert(t, actual.Fetched[0].CommonName, "Test Intermediate", "")
+	assert(t, strings.Join(findingsOf(actual), "\n"), strings.Join([]string{
+		"1 error was issued by CN=Test Intermediate, which was not sent, it can be downloaded from " + server.URL + "/intermediate.crt",
+		"1 error was issued by CN=Test Root, which was not sent either, it can be downloaded from " + server.URL + "/root.crt",
+	}, "\n"), "")
+}
+
+func Test_CheckServer_incompleteChain(t *testing.T) {
+	root := newTestCA(t, "Test Root")
+	intermediate := newTestIntermediate(t, root, "Test Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_ERROR || actual.Chain.Complete {
+		t.Fatal("expected a missing intermediate to fail the check")
+	}
+	assert(t, actual.Err, "certificate 1 was issued by CN=Test Intermediate, which was not sent", "")
+	if !strings.Contains(actual.AsString(false), " 1) localhost expires on "+displayDate(actual.Certs[0].ValidNotAfter)+"\n      error was issued by CN=Test Intermediate, which was not sent\n") {
+		t.Error("expected the finding under the certificate in text output", actual.AsString(false))
+	}
+}
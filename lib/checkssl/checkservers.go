@@ -0,0 +1,70 @@
+package checkssl
+
+import "sync"
+
+type indexedResult struct {
+	index  int
+	result CheckedServer
+}
+
+// CheckServers checks every target with up to concurrency checks running at
+// once. Results are sent in the same order as targets, or as soon as each
+// check finishes when inCompletionOrder is set. The channel is closed once
+// every target has been checked.
+func (a *CheckSSL) CheckServers(targets []string, concurrency int, inCompletionOrder bool) <-chan CheckedServer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	completed := make(chan indexedResult)
+	output := make(chan CheckedServer)
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency && i < len(targets); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range jobs {
+				completed <- indexedResult{index: index, result: a.CheckServer(targets[index], false)}
+			}
+		}()
+	}
+
+	go func() {
+		for index := range targets {
+			jobs <- index
+		}
+		close(jobs)
+		workers.Wait()
+		close(completed)
+	}()
+
+	go func() {
+		defer close(output)
+		if inCompletionOrder {
+			for finished := range completed {
+				output <- finished.result
+			}
+			return
+		}
+
+		// hold on to results that finish early until everything before them is sent
+		waiting := map[int]CheckedServer{}
+		next := 0
+		for finished := range completed {
+			waiting[finished.index] = finished.result
+			for {
+				result, found := waiting[next]
+				if !found {
+					break
+				}
+				output <- result
+				delete(waiting, next)
+				next++
+			}
+		}
+	}()
+
+	return output
+}
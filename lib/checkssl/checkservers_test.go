@@ -0,0 +1,75 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startDelayedTlsServer(t *testing.T, delay time.Duration) string {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	handshake := serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}})
+	return startTestServer(t, func(conn net.Conn) {
+		time.Sleep(delay)
+		handshake(conn)
+	})
+}
+
+func collectTargets(results <-chan CheckedServer) (targets []string) {
+	for result := range results {
+		targets = append(targets, result.Target)
+	}
+	return
+}
+
+func Test_CheckServers_inputOrder(t *testing.T) {
+	slow := "tls://" + startDelayedTlsServer(t, 300*time.Millisecond)
+	fast := "tls://" + startDelayedTlsServer(t, 0)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := collectTargets(checker.CheckServers([]string{slow, fast, fast}, 3, false))
+	assert(t, strings.Join(actual, " "), strings.Join([]string{slow, fast, fast}, " "), "results should be in input order")
+}
+
+func Test_CheckServers_completionOrder(t *testing.T) {
+	slow := "tls://" + startDelayedTlsServer(t, 300*time.Millisecond)
+	fast := "tls://" + startDelayedTlsServer(t, 0)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := collectTargets(checker.CheckServers([]string{slow, fast}, 2, true))
+	assert(t, strings.Join(actual, " "), fast+" "+slow, "the fast server should finish first")
+}
+
+func Test_CheckServers_empty(t *testing.T) {
+	checker := NewCheckSSL()
+	actual := collectTargets(checker.CheckServers(nil, 0, false))
+	if len(actual) != 0 {
+		t.Error("expected no results for no targets")
+	}
+}
+
+func Test_AsString_concurrentColors(t *testing.T) {
+	result := generateRealisticResult()
+	plain := result.AsString(false)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			_ = result.AsString(true)
+		}()
+		go func() {
+			defer wait.Done()
+			if result.AsString(false) != plain {
+				t.Error("rendering without color should not be affected by other renders")
+			}
+		}()
+	}
+	wait.Wait()
+}
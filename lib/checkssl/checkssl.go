@@ -1,13 +1,16 @@
 package checkssl
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -18,6 +21,8 @@ const (
 	RETURNCODE_THRESHOLDFAIL = 3
 	RETURNCODE_NOTVALIDYET   = 4
 	RETURNCODE_ERROR         = 5
+	RETURNCODE_REVOKED       = 6
+	RETURNCODE_WEAKKEY       = 7
 
 	dateLayout = "2006-01-02 3:04PM Mon"
 
@@ -25,17 +30,37 @@ const (
 )
 
 type CheckedServer struct {
-	Target       string
-	Err          string
-	ExitCode     int
-	ServerInfo   string
-	Certs        []CheckCert
-	Passed       bool
-	HttpVersion  string
-	TlsVersion   uint16
-	TlsAlgorithm uint16
-	ServerName   string
-	IpAddress    string
+	Target            string
+	Err               string
+	ExitCode          int
+	ServerInfo        string
+	Certs             []CheckCert
+	Passed            bool
+	HttpVersion       string
+	TlsVersion        uint16
+	TlsAlgorithm      uint16
+	CipherSuite       *CipherSuiteInfo `json:",omitempty"`
+	KeyExchangeGroup  string           `json:",omitempty"`
+	ServerName        string
+	IpAddress         string
+	Nodes             []CheckedServer `json:",omitempty"`
+	Redirects         []RedirectHop   `json:",omitempty"`
+	Stapled           bool
+	MustStaple        bool
+	Staple            *RevocationStatus        `json:",omitempty"`
+	Ct                *CertificateTransparency `json:",omitempty"`
+	Hostname          *HostnameCoverage        `json:",omitempty"`
+	Chain             *ChainAnalysis           `json:",omitempty"`
+	TrustStore        string                   `json:",omitempty"`
+	ClientAuth        *ClientAuth              `json:",omitempty"`
+	TlsVersions       []TlsVersionSupport      `json:",omitempty"`
+	CipherSuites      []TlsCipherSuites        `json:",omitempty"`
+	WeakCipherSuites  bool                     `json:",omitempty"`
+	LegacyVersions    []LegacyVersionSupport   `json:",omitempty"`
+	ClientSimulations []ClientSimulation       `json:",omitempty"`
+	Hsts              *HstsAudit               `json:",omitempty"`
+
+	leafHash [sha256.Size]byte
 }
 type CheckCert struct {
 	CommonName             string
@@ -43,17 +68,81 @@ type CheckCert struct {
 	ValidNotBefore         time.Time
 	ValidNotAfter          time.Time
 	IsInvalid              bool
+	Ocsp                   *RevocationStatus `json:",omitempty"`
+	Crl                    *RevocationStatus `json:",omitempty"`
+	Subject                string
+	Issuer                 string
+	SerialNumber           string
+	DnsNames               []string `json:",omitempty"`
+	IpAddresses            []string `json:",omitempty"`
+	Uris                   []string `json:",omitempty"`
+	EmailAddresses         []string `json:",omitempty"`
+	Sha256Fingerprint      string
+	SpkiSha256Fingerprint  string
+	PublicKeyAlgorithm     string
+	PublicKeySize          int
+	PublicKeyCurve         string `json:",omitempty"`
+	SignatureAlgorithm     string
+	KeyUsage               []string `json:",omitempty"`
+	ExtKeyUsage            []string `json:",omitempty"`
+	OcspServers            []string `json:",omitempty"`
+	IssuingCertificateUrls []string `json:",omitempty"`
+	CrlDistributionPoints  []string `json:",omitempty"`
+	PolicyOids             []string `json:",omitempty"`
+	Strength               string   `json:",omitempty"`
+	StrengthIssues         []string `json:",omitempty"`
 }
 
 type CheckSSL struct {
-	timeoutSeconds     int
-	dateNeededValidFor time.Time
+	timeoutSeconds       int
+	dateNeededValidFor   time.Time
+	rawTls               bool
+	checkAllIps          bool
+	nodeHost             string
+	nodeIp               string
+	connectTo            map[string]string
+	proxy                *url.URL
+	proxyFromEnvironment bool
+	maxRedirects         int
+	ocsp                 bool
+	ocspResponder        string
+	crl                  bool
+	crlCacheDir          string
+	crlFiles             []localCrl
+	crls                 *crlCache
+	ct                   bool
+	ctLogs               map[[sha256.Size]byte]*ctLog
+	fetchIntermediates   bool
+	rootCAs              *x509.CertPool
+	caCerts              []*x509.Certificate
+	caSources            []string
+	appendSystemRoots    bool
+	clientCert           *tls.Certificate
+	minRsaBits           int
+	minEcBits            int
+	probeTlsVersions     bool
+	minTlsVersion        uint16
+	probeCipherSuites    bool
+	probeLegacy          bool
+	simulateClients      bool
+	clientProfiles       []clientProfile
+	hsts                 bool
+	hstsRequired         bool
+	hstsMinAge           int
+	hstsPreload          map[string]bool
 }
 
 func NewCheckSSL() CheckSSL {
 	return CheckSSL{
-		timeoutSeconds:     DEFAULT_TIMEOUT_SEC,
-		dateNeededValidFor: time.Now(),
+		timeoutSeconds:       DEFAULT_TIMEOUT_SEC,
+		dateNeededValidFor:   time.Now(),
+		proxyFromEnvironment: true,
+		maxRedirects:         DEFAULT_MAX_REDIRECTS,
+		crlCacheDir:          defaultCrlCacheDir(),
+		crls:                 &crlCache{downloads: map[string]*crlDownload{}},
+		minRsaBits:           DEFAULT_MIN_RSA_BITS,
+		minEcBits:            DEFAULT_MIN_EC_BITS,
+		minTlsVersion:        DEFAULT_MIN_TLS_VERSION,
 	}
 }
 func (a *CheckSSL) SetTimeout(seconds int) {
@@ -63,7 +152,41 @@ func (a *CheckSSL) SetThreshold(threshold time.Time) {
 	a.dateNeededValidFor = threshold
 }
 
+// SetRawTls forces every target to be checked with a plain TLS handshake
+// instead of an HTTPS request.
+func (a *CheckSSL) SetRawTls(enable bool) {
+	a.rawTls = enable
+}
+
+// SetCheckAllIps checks every A and AAAA record of a target's host instead of
+// only the first address that answers.
+func (a *CheckSSL) SetCheckAllIps(enable bool) {
+	a.checkAllIps = enable
+}
+
+// SetConnectTo sends connections for address ("host:port") to connectTo
+// ("ip:port") instead, while SNI and certificate verification still use the
+// original host. Either half of address can be left blank to match any host
+// or any port, like curl's --connect-to.
+func (a *CheckSSL) SetConnectTo(address string, connectTo string) {
+	if a.connectTo == nil {
+		a.connectTo = map[string]string{}
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err == nil {
+		address = net.JoinHostPort(host, port)
+	}
+	a.connectTo[address] = connectTo
+}
+
 func (a *CheckSSL) CheckServer(target string, insecure bool) (output CheckedServer) {
+	if a.checkAllIps {
+		return a.checkEveryIp(target, insecure)
+	}
+	if a.shouldUseRawTls(target) {
+		return a.checkRawTls(target, insecure)
+	}
+
 	target = strings.Replace(target, "http://", "https://", 1)
 	if !strings.HasPrefix(target, "https://") {
 		target = "https://" + target
@@ -72,22 +195,17 @@ func (a *CheckSSL) CheckServer(target string, insecure bool) (output CheckedServ
 	output.Target = target
 	output.Passed = true
 
-	dialerContext := &net.Dialer{
-		Timeout: time.Duration(a.timeoutSeconds) * time.Second,
-	}
-
 	tr := &http.Transport{
-		TLSClientConfig:   &tls.Config{InsecureSkipVerify: insecure},
+		TLSClientConfig:   a.tlsConfig("", insecure, &output),
 		ForceAttemptHTTP2: true,
-		DialContext:       dialerContext.DialContext,
+		DialContext:       a.dialContext,
 	}
 
 	trace := &httptrace.ClientTrace{
 		GotConn: func(connInfo httptrace.GotConnInfo) {
 			// TODO(sz) get a debug logger so people can see these state changes
 			//fmt.Printf("  gotConn %+v", connInfo.Conn)
-			ip, _, _ := net.SplitHostPort(connInfo.Conn.RemoteAddr().String())
-			output.IpAddress = ip
+			output.IpAddress = remoteIp(connInfo.Conn)
 		},
 		DNSDone: func(dnsInfo httptrace.DNSDoneInfo) {
 			//fmt.Printf("  dnsDone %+v", dnsInfo)
@@ -113,7 +231,7 @@ func (a *CheckSSL) CheckServer(target string, insecure bool) (output CheckedServ
 
 	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 
-	client := &http.Client{Transport: tr}
+	client := &http.Client{Transport: tr, CheckRedirect: a.checkRedirect(&output)}
 	response, err := client.Do(req)
 	if err != nil {
 		if !insecure {
@@ -124,7 +242,7 @@ func (a *CheckSSL) CheckServer(target string, insecure bool) (output CheckedServ
 		}
 		certError := errors.Unwrap(err)
 		if certError != nil {
-			output.Err = certError.Error()
+			output.Err = a.trustError(certError)
 		}
 		output.Passed = false
 		output.ExitCode = RETURNCODE_ERROR
@@ -141,39 +259,122 @@ func (a *CheckSSL) CheckServer(target string, insecure bool) (output CheckedServ
 	}
 
 	if response.TLS != nil {
-		output.ServerName = response.TLS.ServerName
-		output.TlsVersion = response.TLS.Version
-		output.TlsAlgorithm = response.TLS.CipherSuite
 		output.HttpVersion = response.TLS.NegotiatedProtocol
+		if !insecure {
+			output.TrustStore = a.trustStore()
+		}
+		a.checkConnectionState(&output, response.TLS, response.Request.URL.Hostname())
+		port := req.URL.Port()
+		if port == "" {
+			port = "443"
+		}
+		address := net.JoinHostPort(req.URL.Hostname(), port)
+		if a.probeTlsVersions {
+			a.checkTlsVersions(&output, req.URL.Hostname(), address, nil)
+		}
+		if a.probeCipherSuites {
+			a.checkCipherSuites(&output, req.URL.Hostname(), address, nil)
+		}
+		if a.probeLegacy {
+			a.checkLegacy(&output, req.URL.Hostname(), address, nil)
+		}
+		if a.simulateClients {
+			a.checkClients(&output, req.URL.Hostname(), address, nil)
+		}
+		if a.hsts {
+			a.checkHsts(&output, response)
+		}
+	} else {
+		output.Passed = false
+		output.Err = "Missing TLS Connection"
+	}
 
-		for _, val := range response.TLS.PeerCertificates {
-			certInfo := CheckCert{}
-			certInfo.IsCertificateAuthority = val.IsCA
-			certInfo.ValidNotAfter = val.NotAfter
-			certInfo.ValidNotBefore = val.NotBefore
+	if len(output.Redirects) > 0 {
+		output.Redirects = append(output.Redirects, output.finalHop(response))
+	}
+	return
+}
 
-			commonName := val.Subject.CommonName
-			if commonName == "" {
-				commonName = "(missing common name)"
-			}
-			certInfo.CommonName = commonName
-			if output.ServerName == "" {
-				output.ServerName = commonName
-			}
+// checkConnectionState fills in the negotiated TLS details and evaluates every
+// certificate the server presented for host, regardless of how the handshake
+// was made.
+func (a *CheckSSL) checkConnectionState(output *CheckedServer, state *tls.ConnectionState, host string) {
+	output.ServerName = state.ServerName
+	output.TlsVersion = state.Version
+	output.TlsAlgorithm = state.CipherSuite
+	suite := cipherSuiteInfo(state.CipherSuite)
+	output.CipherSuite = &suite
+	output.KeyExchangeGroup = keyExchangeGroupName(state.CurveID)
+	if len(state.PeerCertificates) > 0 {
+		output.leafHash = sha256.Sum256(state.PeerCertificates[0].Raw)
+		output.Hostname = checkHostname(host, state.PeerCertificates[0])
+	}
 
-			newCode := checkIfExpirationIsWithinTolerance(a.dateNeededValidFor, val.NotBefore, val.NotAfter)
-			if newCode > RETURNCODE_PASS {
-				certInfo.IsInvalid = true
-				output.ExitCode = newCode
-				output.Passed = false
-			}
-			output.Certs = append(output.Certs, certInfo)
+	certs, exitCode := a.checkCertificates(state.PeerCertificates)
+	if exitCode > RETURNCODE_PASS {
+		output.ExitCode = exitCode
+		output.Passed = false
+	}
+	if output.ServerName == "" && len(certs) > 0 {
+		output.ServerName = certs[0].CommonName
+	}
+	output.Certs = append(output.Certs, certs...)
+
+	output.Chain = a.analyzeChain(state.PeerCertificates)
+	if broken := output.Chain.failed(); broken != "" {
+		if output.Err == "" {
+			output.Err = broken
+		}
+		if output.ExitCode == RETURNCODE_PASS {
+			output.ExitCode = RETURNCODE_ERROR
 		}
-	} else {
 		output.Passed = false
-		output.Err = "Missing TLS Connection"
 	}
+	a.checkStaple(output, state)
+	if a.ct {
+		a.checkCt(output, state)
+	}
+}
+
+// checkCertificates evaluates a chain as presented by the server, returning
+// the exit code of the last certificate that failed.
+func (a *CheckSSL) checkCertificates(chain []*x509.Certificate) (output []CheckCert, exitCode int) {
+	for i, val := range chain {
+		certInfo := CheckCert{}
+		certInfo.IsCertificateAuthority = val.IsCA
+		certInfo.ValidNotAfter = val.NotAfter
+		certInfo.ValidNotBefore = val.NotBefore
+
+		commonName := val.Subject.CommonName
+		if commonName == "" {
+			commonName = "(missing common name)"
+		}
+		certInfo.CommonName = commonName
+		certInfo.setDetails(val)
 
+		newCode := checkIfExpirationIsWithinTolerance(a.dateNeededValidFor, val.NotBefore, val.NotAfter)
+		if newCode > RETURNCODE_PASS {
+			certInfo.IsInvalid = true
+			exitCode = newCode
+		}
+
+		if a.ocsp && i == 0 {
+			certInfo.Ocsp = a.checkOcsp(val, issuerOf(chain, i))
+		}
+		if a.crl {
+			certInfo.Crl = a.checkCrl(val, issuerOf(chain, i))
+		}
+		if certInfo.revocation() != nil {
+			certInfo.IsInvalid = true
+			exitCode = RETURNCODE_REVOKED
+		}
+
+		a.checkStrength(&certInfo, val)
+		if certInfo.Strength == STRENGTH_FAIL && exitCode == RETURNCODE_PASS {
+			exitCode = RETURNCODE_WEAKKEY
+		}
+		output = append(output, certInfo)
+	}
 	return
 }
 
@@ -214,9 +415,34 @@ func durationDays(before time.Time, after time.Time) string {
 	return fmt.Sprintf("%.1f", after.Sub(before).Hours()/24)
 }
 
-func (a CheckedServer) AsString(enableColors bool) (output string) {
-	setTerminalColor(enableColors)
+func (a CheckedServer) AsString(enableColors bool) string {
+	return a.asString(newTerminalColors(enableColors), false)
+}
+
+// AsVerboseString is the same as AsString with every detail of each
+// certificate listed under it.
+func (a CheckedServer) AsVerboseString(enableColors bool) string {
+	return a.asString(newTerminalColors(enableColors), true)
+}
+
+func (a CheckedServer) asString(color terminalColors, verbose bool) (output string) {
+	for _, node := range a.Nodes {
+		output += node.details(color, verbose)
+		output += node.nodeLine(color)
+	}
+	if len(a.Nodes) > 0 && a.Err != "" {
+		output += fmt.Sprintf("%s%s%s\n", color.red, a.Err, color.noColor)
+	}
+
+	output += a.details(color, verbose)
+	for _, hop := range a.Redirects {
+		output += hop.asString(color)
+	}
+	output += a.summaryLine(color)
+	return
+}
 
+func (a CheckedServer) details(color terminalColors, verbose bool) (output string) {
 	if a.ServerName != "" && a.IpAddress != "" {
 		output += fmt.Sprintf("\n%s => %s\n", a.ServerName, a.IpAddress)
 	}
@@ -227,9 +453,24 @@ func (a CheckedServer) AsString(enableColors bool) (output string) {
 		} else {
 			output += fmt.Sprintf(" -> %s\n", expandServerNames(a.ServerInfo))
 		}
-		output += fmt.Sprintf(" -> %s with %s\n", getHttpVersion(a.HttpVersion), getTlsVersion(a.TlsVersion))
-		output += fmt.Sprintf(" -> %s %s\n", getTlsAlgo(a.TlsAlgorithm), getMozillaRecommendedCipher(a.TlsAlgorithm))
+		output += fmt.Sprintf(" -> %s with %s\n", getHttpVersion(a.HttpVersion, color), getTlsVersion(a.TlsVersion, color))
+		output += fmt.Sprintf(" -> %s %s\n", getTlsAlgo(a.TlsAlgorithm), getMozillaRecommendedCipher(a.TlsAlgorithm, color))
+		output += a.keyExchangeGroupLine()
+	} else if a.TlsAlgorithm > 0 {
+		// raw TLS checks never make an HTTP request
+		output += fmt.Sprintf(" -> %s\n", getTlsVersion(a.TlsVersion, color))
+		output += fmt.Sprintf(" -> %s %s\n", getTlsAlgo(a.TlsAlgorithm), getMozillaRecommendedCipher(a.TlsAlgorithm, color))
+		output += a.keyExchangeGroupLine()
 	}
+	output += a.tlsVersionLines(color)
+	output += a.legacyLines(color)
+	output += a.cipherSuiteLines(color)
+	output += a.clientSimulationLines()
+	output += a.hstsLines(color)
+	output += a.stapleLine(color)
+	output += a.ctLine(color)
+	output += a.Hostname.asString(color)
+	output += a.clientAuthLine(color)
 
 	for i, cert := range a.Certs {
 
@@ -239,29 +480,43 @@ func (a CheckedServer) AsString(enableColors bool) (output string) {
 			output += fmt.Sprintf(" %d) ", i+1)
 		}
 
-		if cert.IsInvalid {
-			output += fmt.Sprintf("%s%s expired on %s%s", terminalRed, cert.CommonName, displayDate(cert.ValidNotAfter), terminalNoColor)
+		if revoked := cert.revocation(); revoked != nil {
+			output += fmt.Sprintf("%s%s was REVOKED on %s (%s)%s", color.red, cert.CommonName, displayDate(revoked.RevokedAt), revoked.Reason, color.noColor)
+		} else if cert.IsInvalid {
+			output += fmt.Sprintf("%s%s expired on %s%s", color.red, cert.CommonName, displayDate(cert.ValidNotAfter), color.noColor)
 		} else {
 			output += fmt.Sprintf("%s expires on %s", cert.CommonName, displayDate(cert.ValidNotAfter))
 		}
+		output += cert.Ocsp.asString("OCSP", color)
+		output += cert.Crl.asString("CRL", color)
 		output += "\n"
+		if verbose {
+			output += cert.verboseDetails()
+		}
+		output += cert.strengthFindings(color)
+		output += a.Chain.findingsFor(i+1, color)
 	}
-
-	output += a.summaryLine()
 	return
 }
 
+func (a CheckedServer) nodeLine(color terminalColors) string {
+	if a.Passed {
+		return fmt.Sprintf(" %s[PASS]%s %s\n", color.green, color.noColor, a.IpAddress)
+	}
+	return fmt.Sprintf(" %s[FAIL]%s %s %s\n", color.red, color.noColor, a.IpAddress, a.Err)
+}
+
 func (a CheckedServer) AsShortString(enableColors bool) (output string) {
-	setTerminalColor(enableColors)
-	output = a.summaryLine()
+	color := newTerminalColors(enableColors)
+	output = a.summaryLine(color)
 	return
 }
 
-func (a CheckedServer) summaryLine() string {
+func (a CheckedServer) summaryLine(color terminalColors) string {
 	if a.Passed {
-		return fmt.Sprintf("%s[PASS]%s %s\n", terminalGreen, terminalNoColor, a.Target)
+		return fmt.Sprintf("%s[PASS]%s %s\n", color.green, color.noColor, a.Target)
 	}
-	return fmt.Sprintf("%s[FAIL]%s %s\n", terminalRed, terminalNoColor, a.Target)
+	return fmt.Sprintf("%s[FAIL]%s %s\n", color.red, color.noColor, a.Target)
 }
 
 type OutputFormat int64
@@ -272,6 +527,7 @@ const (
 	TEXT
 	NONE
 	SHORT
+	VERBOSE
 )
 
 func (a OutputFormat) String() string {
@@ -286,6 +542,8 @@ func (a OutputFormat) String() string {
 		return "NONE"
 	case SHORT:
 		return "SHORT"
+	case VERBOSE:
+		return "VERBOSE"
 	}
 	return ""
 }
@@ -299,14 +557,14 @@ func (a CheckedServer) AsJson() string {
 }
 
 func CsvHeaderRow() string {
-	return "Target,Result,Days to Expire,Duration,Common Name,CA Name,Error"
+	return "Target,Result,Days to Expire,Duration,Common Name,CA Name,OCSP Staple,Cipher Suite,Cipher Strength,Key Exchange Group,Error"
 }
 func (a CheckedServer) AsCsv() string {
 	var leastDays time.Time
 	duration := ""
 	commonName := ""
 	caName := ""
-	for i, cert := range a.Certs {
+	for i, cert := range a.allCerts() {
 		if leastDays.After(cert.ValidNotAfter) || leastDays.IsZero() {
 			leastDays = cert.ValidNotAfter
 		}
@@ -318,104 +576,97 @@ func (a CheckedServer) AsCsv() string {
 			caName = cert.CommonName
 		}
 	}
-	return strings.Join([]string{a.Target, csvConvertResult(a.ExitCode), numberOfDays(leastDays), duration, commonName, caName, a.Err}, ",")
+	cipherSuite, cipherStrength := "", ""
+	if a.TlsAlgorithm > 0 {
+		suite := cipherSuiteInfo(a.TlsAlgorithm)
+		cipherSuite, cipherStrength = suite.Name, suite.Strength
+	}
+	return strings.Join([]string{a.Target, csvConvertResult(a.ExitCode), numberOfDays(leastDays), duration, commonName, caName, a.stapleCsv(), cipherSuite, cipherStrength, a.KeyExchangeGroup, a.Err}, ",")
+}
+
+// allCerts includes the certs from every node when each ip was checked on its own.
+func (a CheckedServer) allCerts() []CheckCert {
+	output := append([]CheckCert{}, a.Certs...)
+	for _, node := range a.Nodes {
+		output = append(output, node.Certs...)
+	}
+	return output
 }
+
 func csvConvertResult(input int) string {
 	if input == 0 {
 		return "PASS"
 	} else if input == RETURNCODE_EXPIRED {
 		return "EXPIRED"
+	} else if input == RETURNCODE_REVOKED {
+		return "REVOKED"
+	} else if input == RETURNCODE_WEAKKEY {
+		return "WEAK"
 	}
 	return "FAIL"
 }
 
-var terminalNoColor = ""
-var terminalRed = ""
-var terminalYellow = ""
-var terminalGreen = ""
+// terminalColors holds the escape codes used while rendering one result, so
+// results can be rendered concurrently with and without color.
+type terminalColors struct {
+	noColor string
+	red     string
+	yellow  string
+	green   string
+}
 
-func setTerminalColor(enable bool) {
+func newTerminalColors(enable bool) terminalColors {
 	if enable {
-		terminalNoColor = "\033[0m"
-		terminalRed = "\033[31m"
-		terminalYellow = "\033[0;33m"
-		terminalGreen = "\033[0;32m"
-	} else {
-		terminalNoColor = ""
-		terminalRed = ""
-		terminalYellow = ""
-		terminalGreen = ""
+		return terminalColors{
+			noColor: "\033[0m",
+			red:     "\033[31m",
+			yellow:  "\033[0;33m",
+			green:   "\033[0;32m",
+		}
 	}
+	return terminalColors{}
 }
 
-func getTlsVersion(input uint16) string {
+func getTlsVersion(input uint16, color terminalColors) string {
 	switch input {
 	case tls.VersionSSL30:
-		return terminalRed + "SSL v3.0 () - PLEASE UPGRADE to TLS v1.2" + terminalNoColor
+		return color.red + "SSL v3.0 () - PLEASE UPGRADE to TLS v1.2" + color.noColor
 	case tls.VersionTLS10:
-		return terminalRed + "TLS v1.0 (released 1999) - PLEASE UPGRADE to TLS v1.2 or v1.3" + terminalNoColor
+		return color.red + "TLS v1.0 (released 1999) - PLEASE UPGRADE to TLS v1.2 or v1.3" + color.noColor
 	case tls.VersionTLS11:
-		return terminalRed + "TLS v1.1 (release 2006) - PLEASE UPGRADE to TLS v1.2 or v1.3" + terminalNoColor
+		return color.red + "TLS v1.1 (release 2006) - PLEASE UPGRADE to TLS v1.2 or v1.3" + color.noColor
 	case tls.VersionTLS12:
-		return terminalYellow + "TLS v1.2 (released 2008) - Consider upgrading to TLS v1.3" + terminalNoColor
+		return color.yellow + "TLS v1.2 (released 2008) - Consider upgrading to TLS v1.3" + color.noColor
 	case tls.VersionTLS13:
-		return terminalGreen + "TLS v1.3 (released 2018) - latest version" + terminalNoColor
+		return color.green + "TLS v1.3 (released 2018) - latest version" + color.noColor
 	}
 
 	return fmt.Sprintf("unknown TLS version: %d", input)
 }
 
-func getMozillaRecommendedCipher(input uint16) string {
+func getMozillaRecommendedCipher(input uint16, color terminalColors) string {
 	// Mozilla Recommended Ciphers - https://ssl-config.mozilla.org/
-	// ECDHE-ECDSA-AES128-GCM-SHA256
-	// ECDHE-RSA-AES128-GCM-SHA256
-	// ECDHE-ECDSA-AES256-GCM-SHA384
-	// ECDHE-RSA-AES256-GCM-SHA384
-	// ECDHE-ECDSA-CHACHA20-POLY1305
-	// ECDHE-RSA-CHACHA20-POLY1305
-	// DHE-RSA-AES128-GCM-SHA256 ?
-	// DHE-RSA-AES256-GCM-SHA384 ?
-	if input == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 ||
-		input == tls.TLS_RSA_WITH_AES_128_GCM_SHA256 ||
-		input == tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 ||
-		input == tls.TLS_RSA_WITH_AES_256_GCM_SHA384 ||
-		input == tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305 ||
-		input == tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305 {
-		return terminalGreen + " (Mozilla Recommended Cipher)" + terminalNoColor
+	if cipherSuiteInfo(input).Strength == CIPHER_RECOMMENDED {
+		return color.green + " (Mozilla Recommended Cipher)" + color.noColor
 	}
 	return "" // not insecure, but consider upgrading
 }
 
 func getTlsAlgo(input uint16) string {
-	switch input {
-	case tls.TLS_AES_128_GCM_SHA256:
-		return "TLS_AES_128_GCM_SHA256 = TLS, message encrypted with AES128 GCM, hashes are SHA256"
-	case tls.TLS_AES_256_GCM_SHA384:
-		return "TLS_AES_256_GCM_SHA384"
-	case tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:
-		return "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 = TLS, key exchange is ECDHE, auth exchange is ECDSA, messages encrypted with AES128GCM, hashes are SHA256"
-	case tls.TLS_RSA_WITH_AES_256_CBC_SHA:
-		return "TLS_RSA_WITH_AES_256_CBC_SHA - !!! WEAK Cipher, please upgrade !!!"
-	case tls.TLS_CHACHA20_POLY1305_SHA256:
-		return "TLS_CHACHA20_POLY1305_SHA256"
-	case tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:
-		return "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
-	}
-	return fmt.Sprintf("unknown TLS algo: %x", input)
-
+	return cipherSuiteInfo(input).description()
 }
 
-func getHttpVersion(input string) string {
+func getHttpVersion(input string, color terminalColors) string {
 
 	switch strings.ToLower(input) {
 	case "h3":
 		return "HTTP/3" // currently, go cannot do http3 natively
 	case "h2":
-		return terminalGreen + "HTTP/2" + terminalNoColor
+		return color.green + "HTTP/2" + color.noColor
 	case "http/1.1":
-		return terminalRed + "HTTP/1.1 (OLD)" + terminalNoColor
+		return color.red + "HTTP/1.1 (OLD)" + color.noColor
 	case "http/1":
-		return terminalRed + "HTTP/1 (OLD)" + terminalNoColor
+		return color.red + "HTTP/1 (OLD)" + color.noColor
 	}
 	return fmt.Sprintf("unknown HTTPS version %s", input)
 
@@ -429,6 +680,7 @@ func expandServerNames(input string) (output string) {
 	return
 }
 
+// getAllDnsRecordsFor returns every A and AAAA record for a host.
 func getAllDnsRecordsFor(input string) []string {
 	output := []string{}
 	addresses, err := net.LookupIP(input)
@@ -436,12 +688,8 @@ func getAllDnsRecordsFor(input string) []string {
 		return output
 	}
 
-	for i, ip := range addresses {
-		ipv4 := ip.To4()
-		if ipv4 != nil {
-			fmt.Println(i, ip)
-			output = append(output, ipv4.String())
-		}
+	for _, ip := range addresses {
+		output = append(output, ip.String())
 	}
 	return output
 }
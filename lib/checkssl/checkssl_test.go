@@ -1,6 +1,17 @@
 package checkssl
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -39,8 +50,13 @@ func Test_CheckServer_untrustedRoot(t *testing.T) {
 	testFailure(t, "https://untrusted-root.badssl.com/")
 }
 func Test_CheckServer_revoked(t *testing.T) {
-	t.Skipf("This should fail eventually")
-	testFailure(t, "https://revoked.badssl.com/")
+	checker := NewCheckSSL()
+	checker.SetOcsp(true)
+	actual := checker.CheckServer("https://revoked.badssl.com/", false)
+
+	if actual.Passed {
+		t.Fatal("expecting to get a failure reply")
+	}
 }
 func Test_CheckServer_pinningTest(t *testing.T) {
 	t.Skipf("This should fail eventually")
@@ -114,7 +130,7 @@ func Test_AsString_NoColors(t *testing.T) {
 		"www.checkssl.org => 2600:9000:24d0:fa00:1e:e294:3240:93a1\n" +
 		" -> AmazonS3 - \n" +
 		" -> HTTP/2 with TLS v1.3 (released 2018) - latest version\n" +
-		" -> TLS_AES_128_GCM_SHA256 = TLS, message encrypted with AES128 GCM, hashes are SHA256 \n" +
+		" -> TLS_AES_128_GCM_SHA256 = AES_128_GCM cipher, SHA256 hash, recommended  (Mozilla Recommended Cipher)\n" +
 		" 1) *.checkssl.org expires on " + displayDate(results.Certs[0].ValidNotAfter) + "\n" +
 		" CA-2) Amazon RSA 2048 M01 expires on " + displayDate(results.Certs[1].ValidNotAfter) + "\n" +
 		" CA-3) Amazon Root CA 1 expires on " + displayDate(results.Certs[2].ValidNotAfter) + "\n" +
@@ -126,13 +142,13 @@ func Test_AsString_NoColors(t *testing.T) {
 func Test_AsCsv_Pass(t *testing.T) {
 	results := generateRealisticResult()
 	actual := results.AsCsv()
-	expected := "https://checkssl.org,PASS,5.0,10.0,*.checkssl.org,Starfield Services Root Certificate Authority - G2,"
+	expected := "https://checkssl.org,PASS,5.0,10.0,*.checkssl.org,Starfield Services Root Certificate Authority - G2,none,TLS_AES_128_GCM_SHA256,recommended,,"
 	assert(t, actual, expected, "")
 }
 func Test_AsCsv_Fail(t *testing.T) {
 	results := CheckedServer{Target: "example.com", ExitCode: 2, Err: "dial tcp: lookup example.com: no such host"}
 	actual := results.AsCsv()
-	expected := "example.com,EXPIRED,,,,,dial tcp: lookup example.com: no such host"
+	expected := "example.com,EXPIRED,,,,,,,,,dial tcp: lookup example.com: no such host"
 	assert(t, actual, expected, "")
 }
 
@@ -196,7 +212,13 @@ func generateRealisticResult() CheckedServer {
 func Test_getAllDnsRecordsFor(t *testing.T) {
 	actual := getAllDnsRecordsFor("www.checkssl.org")
 
-	if len(actual) != 4 {
+	ipv4 := 0
+	for _, ip := range actual {
+		if net.ParseIP(ip).To4() != nil {
+			ipv4++
+		}
+	}
+	if ipv4 != 4 {
 		t.Log(actual)
 		t.Error("did not get the expected 4 A records")
 	}
@@ -220,3 +242,116 @@ func assert(t *testing.T, actual string, expected string, failureHint string) {
 		t.Fatal("[FAILED]", failureHint)
 	}
 }
+
+type testCertificate struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// newTestCertificate signs template with parent, or self signs it when parent is nil.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.SerialNumber == nil {
+		serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+		template.SerialNumber = serial
+	}
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-24 * time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(30 * 24 * time.Hour)
+	}
+
+	signer, signerCert := crypto.Signer(key), template
+	if parent != nil {
+		signer, signerCert = parent.Key, parent.Cert
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{Cert: cert, Key: key}
+}
+
+func newTestCA(t *testing.T, commonName string) *testCertificate {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil)
+}
+
+func newTestLeaf(t *testing.T, ca *testCertificate, dnsNames ...string) *testCertificate {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:    dnsNames,
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+}
+
+// tlsCertificate bundles the certificate, followed by chain, for use in a tls.Config.
+func (c *testCertificate) tlsCertificate(chain ...*testCertificate) tls.Certificate {
+	output := tls.Certificate{Certificate: [][]byte{c.Cert.Raw}, PrivateKey: c.Key, Leaf: c.Cert}
+	for _, cert := range chain {
+		output.Certificate = append(output.Certificate, cert.Cert.Raw)
+	}
+	return output
+}
+
+// startTestServer accepts connections on a random local port until the test
+// ends, handing each one to handle. It returns the host:port to connect to.
+func startTestServer(t *testing.T, handle func(conn net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// serveTls completes a server side TLS handshake and then holds the connection open briefly.
+func serveTls(config *tls.Config) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		tlsConn := tls.Server(conn, config)
+		if tlsConn.Handshake() == nil {
+			buffer := make([]byte, 1)
+			_, _ = tlsConn.Read(buffer)
+		}
+	}
+}
+
+// startHttpsTestServer serves handler over TLS with cert, or an empty 200 response when handler is nil.
+func startHttpsTestServer(t *testing.T, cert tls.Certificate, handler http.Handler) *httptest.Server {
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,156 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+const (
+	CIPHER_RECOMMENDED = "recommended"
+	CIPHER_SECURE      = "secure"
+	CIPHER_WEAK        = "weak"
+	CIPHER_INSECURE    = "insecure"
+)
+
+// TlsCipherSuites lists the suites a server accepts at one TLS version, in
+// the order the server picked them, best first.
+type TlsCipherSuites struct {
+	Version  uint16
+	Name     string
+	Accepted []CipherSuiteInfo
+}
+
+// SetProbeCipherSuites makes a handshake with each cipher suite Go supports
+// at every TLS version the server accepts, to list the suites it will use.
+func (a *CheckSSL) SetProbeCipherSuites(enable bool) {
+	a.probeCipherSuites = enable
+}
+
+// allCipherSuites includes the insecure suites, since finding those is the point.
+func allCipherSuites() []*tls.CipherSuite {
+	return append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+}
+
+// probedCipherSuites is every suite Go can offer at version. Go's defaults
+// leave out RSA key exchange and 3DES, which old servers may only have. TLS
+// v1.3 suites can not be chosen, nil leaves them to Go.
+func probedCipherSuites(version uint16) (output []uint16) {
+	if version == tls.VersionTLS13 {
+		return nil
+	}
+	for _, suite := range allCipherSuites() {
+		if supportsVersion(suite, version) {
+			output = append(output, suite.ID)
+		}
+	}
+	return
+}
+
+// checkCipherSuites tries every suite on its own to find the accepted ones,
+// then offers the accepted ones together and removes the one picked each
+// time to find the server's order. Go does not let a client choose TLS v1.3
+// suites, so only the suite the server picked is shown for TLS v1.3.
+func (a *CheckSSL) checkCipherSuites(output *CheckedServer, serverName string, address string, upgrade tlsUpgrade) {
+	versions := probedTlsVersions
+	if output.TlsVersions != nil {
+		versions = nil
+		for _, support := range output.TlsVersions {
+			if support.Accepted {
+				versions = append(versions, support.Version)
+			}
+		}
+	}
+
+	output.CipherSuites, output.WeakCipherSuites = nil, false
+	for _, version := range versions {
+		result := TlsCipherSuites{Version: version, Name: tls.VersionName(version)}
+		if version == tls.VersionTLS13 {
+			state, err := a.probeHandshake(serverName, address, upgrade, version, nil)
+			if err == nil {
+				result.Accepted = append(result.Accepted, cipherSuiteInfo(state.CipherSuite))
+			}
+		} else {
+			var accepted []uint16
+			for _, suite := range allCipherSuites() {
+				if !supportsVersion(suite, version) {
+					continue
+				}
+				_, err := a.probeHandshake(serverName, address, upgrade, version, []uint16{suite.ID})
+				if err == nil {
+					accepted = append(accepted, suite.ID)
+				}
+			}
+			result.Accepted = a.cipherSuiteOrder(serverName, address, upgrade, version, accepted)
+		}
+		for _, suite := range result.Accepted {
+			if suite.Strength != CIPHER_RECOMMENDED && suite.Strength != CIPHER_SECURE {
+				output.WeakCipherSuites = true
+			}
+		}
+		if len(result.Accepted) > 0 {
+			output.CipherSuites = append(output.CipherSuites, result)
+		}
+	}
+}
+
+func (a *CheckSSL) cipherSuiteOrder(serverName string, address string, upgrade tlsUpgrade, version uint16, remaining []uint16) (output []CipherSuiteInfo) {
+	for len(remaining) > 0 {
+		state, err := a.probeHandshake(serverName, address, upgrade, version, remaining)
+		picked := -1
+		for i, id := range remaining {
+			if err == nil && id == state.CipherSuite {
+				picked = i
+			}
+		}
+		if picked == -1 {
+			break // keep the order they were found in
+		}
+		output = append(output, cipherSuiteInfo(remaining[picked]))
+		remaining = append(remaining[:picked:picked], remaining[picked+1:]...)
+	}
+	for _, id := range remaining {
+		output = append(output, cipherSuiteInfo(id))
+	}
+	return
+}
+
+// probeHandshake only cares whether the server will use this version and
+// these suites, so the certificate is not verified.
+func (a *CheckSSL) probeHandshake(serverName string, address string, upgrade tlsUpgrade, version uint16, suites []uint16) (*tls.ConnectionState, error) {
+	config := a.tlsConfig(serverName, true, &CheckedServer{})
+	config.MinVersion, config.MaxVersion = version, version
+	config.CipherSuites = suites
+	state, _, err := a.tlsHandshake(config, address, upgrade)
+	return state, err
+}
+
+func supportsVersion(suite *tls.CipherSuite, version uint16) bool {
+	for _, supported := range suite.SupportedVersions {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (a CheckedServer) cipherSuiteLines(color terminalColors) (output string) {
+	for _, version := range a.CipherSuites {
+		output += fmt.Sprintf(" -> %s cipher suites, in server preference order:\n", version.Name)
+		for _, suite := range version.Accepted {
+			switch suite.Strength {
+			case CIPHER_RECOMMENDED:
+				output += fmt.Sprintf("      %s%s\n", suite.Name, getMozillaRecommendedCipher(suite.Id, color))
+			case CIPHER_SECURE:
+				output += fmt.Sprintf("      %s %s\n", suite.Name, suite.Strength)
+			case CIPHER_INSECURE:
+				output += fmt.Sprintf("      %s%s %s - %s%s\n", color.red, suite.Name, suite.Strength, suite.Reason, color.noColor)
+			default:
+				output += fmt.Sprintf("      %s%s %s - %s%s\n", color.yellow, suite.Name, suite.Strength, suite.Reason, color.noColor)
+			}
+		}
+	}
+	if a.WeakCipherSuites {
+		output += fmt.Sprintf(" -> %sserver accepts weak cipher suites%s\n", color.yellow, color.noColor)
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func Test_getMozillaRecommendedCipher(t *testing.T) {
+	color := newTerminalColors(false)
+	for _, recommended := range []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305, tls.TLS_AES_128_GCM_SHA256, 0x009E} { // 0x009E is TLS_DHE_RSA_WITH_AES_128_GCM_SHA256
+		assert(t, getMozillaRecommendedCipher(recommended, color), " (Mozilla Recommended Cipher)", tls.CipherSuiteName(recommended))
+	}
+	for _, notRecommended := range []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA} {
+		assert(t, getMozillaRecommendedCipher(notRecommended, color), "", tls.CipherSuiteName(notRecommended))
+	}
+}
+
+func Test_CheckServer_probeCipherSuites(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{
+		Certificates: []tls.Certificate{leaf.tlsCertificate(ca)},
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS13,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		},
+	}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetProbeTlsVersions(true)
+	checker.SetProbeCipherSuites(true)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if len(actual.CipherSuites) != 2 {
+		t.Fatal("expected suites for TLS v1.2 and v1.3, got", actual.CipherSuites)
+	}
+	tls12 := actual.CipherSuites[0]
+	assert(t, tls12.Name, "TLS 1.2", "")
+	var names []string
+	for _, suite := range tls12.Accepted {
+		names = append(names, suite.Name)
+	}
+	if len(names) != 2 || !strings.Contains(strings.Join(names, " "), "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256") || !strings.Contains(strings.Join(names, " "), "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA") {
+		t.Error("expected only the configured suites to be accepted, got", names)
+	}
+	if len(actual.CipherSuites[1].Accepted) != 1 {
+		t.Error("expected the negotiated TLS v1.3 suite", actual.CipherSuites[1])
+	}
+	if !actual.WeakCipherSuites {
+		t.Error("expected the CBC suite to be reported as weak")
+	}
+
+	text := actual.AsString(false)
+	for _, expected := range []string{
+		" -> TLS 1.2 cipher suites, in server preference order:\n",
+		"      TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 (Mozilla Recommended Cipher)\n",
+		"      TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA weak - CBC mode\n",
+		" -> server accepts weak cipher suites\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Error("expected text output to include", expected, text)
+		}
+	}
+}
+
+func Test_CheckServer_probeCipherSuitesLegacyVersions(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	address := startTestServer(t, serveTls(&tls.Config{
+		Certificates: []tls.Certificate{newTestRsaLeaf(t, ca, 2048).tlsCertificate(ca)},
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   tls.VersionTLS13,
+		CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA, tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA},
+	}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetProbeTlsVersions(true)
+	checker.SetProbeCipherSuites(true)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if len(actual.CipherSuites) != 4 {
+		t.Fatal("expected suites for TLS v1.0 through v1.3, got", actual.CipherSuites)
+	}
+	tls10 := actual.CipherSuites[0]
+	assert(t, tls10.Name, "TLS 1.0", "")
+	var names []string
+	for _, suite := range tls10.Accepted {
+		names = append(names, suite.Name)
+	}
+	assert(t, strings.Join(names, ", "), "TLS_RSA_WITH_AES_128_CBC_SHA, TLS_RSA_WITH_3DES_EDE_CBC_SHA", "expected the RSA key exchange and 3DES suites on TLS v1.0")
+	if !actual.WeakCipherSuites {
+		t.Error("expected the RSA key exchange and 3DES suites to be reported as weak")
+	}
+}
@@ -0,0 +1,218 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CipherSuiteInfo breaks an IANA cipher suite name into its parts. TLS v1.3
+// suites only name the cipher and hash, the key exchange and authentication
+// are negotiated separately so they are "any".
+type CipherSuiteInfo struct {
+	Id             uint16
+	Name           string
+	KeyExchange    string
+	Authentication string
+	Cipher         string
+	Mac            string // the MAC for CBC suites, the PRF hash for AEAD suites
+	Strength       string
+	Reason         string `json:",omitempty"`
+}
+
+// keyExchanges maps the part of a name before _WITH_ to its key exchange and
+// authentication.
+var keyExchanges = map[string][2]string{
+	"NULL":            {"NULL", "NULL"},
+	"RSA":             {"RSA", "RSA"},
+	"RSA_EXPORT":      {"RSA_EXPORT", "RSA"},
+	"DH_DSS":          {"DH", "DSS"},
+	"DH_DSS_EXPORT":   {"DH_EXPORT", "DSS"},
+	"DH_RSA":          {"DH", "RSA"},
+	"DH_RSA_EXPORT":   {"DH_EXPORT", "RSA"},
+	"DHE_DSS":         {"DHE", "DSS"},
+	"DHE_DSS_EXPORT":  {"DHE_EXPORT", "DSS"},
+	"DHE_RSA":         {"DHE", "RSA"},
+	"DHE_RSA_EXPORT":  {"DHE_EXPORT", "RSA"},
+	"DH_anon":         {"DH", "anon"},
+	"DH_anon_EXPORT":  {"DH_EXPORT", "anon"},
+	"KRB5":            {"KRB5", "KRB5"},
+	"KRB5_EXPORT":     {"KRB5_EXPORT", "KRB5"},
+	"PSK":             {"PSK", "PSK"},
+	"DHE_PSK":         {"DHE", "PSK"},
+	"PSK_DHE":         {"DHE", "PSK"},
+	"RSA_PSK":         {"RSA", "PSK"},
+	"ECDHE_PSK":       {"ECDHE", "PSK"},
+	"ECDH_ECDSA":      {"ECDH", "ECDSA"},
+	"ECDHE_ECDSA":     {"ECDHE", "ECDSA"},
+	"ECDH_RSA":        {"ECDH", "RSA"},
+	"ECDHE_RSA":       {"ECDHE", "RSA"},
+	"ECDH_anon":       {"ECDH", "anon"},
+	"SRP_SHA":         {"SRP", "SRP"},
+	"SRP_SHA_RSA":     {"SRP", "RSA"},
+	"SRP_SHA_DSS":     {"SRP", "DSS"},
+	"ECCPWD":          {"ECCPWD", "ECCPWD"},
+	"GOSTR341112_256": {"GOSTR341112_256", "GOSTR341112_256"},
+}
+
+// noForwardSecrecy are key exchanges where recording the traffic and later
+// getting the server key is enough to decrypt it.
+var noForwardSecrecy = map[string]bool{"NULL": true, "RSA": true, "DH": true, "ECDH": true, "PSK": true, "KRB5": true}
+
+var macs = map[string]string{"SHA": "SHA1", "SHA256": "SHA256", "SHA384": "SHA384", "SHA512": "SHA512", "MD5": "MD5", "NULL": "NULL", "SM3": "SM3", "OMAC": "OMAC", "IMIT": "IMIT"}
+
+var cipherSuiteCatalog = buildCipherSuiteCatalog()
+
+func buildCipherSuiteCatalog() map[uint16]CipherSuiteInfo {
+	output := make(map[uint16]CipherSuiteInfo, len(ianaCipherSuites))
+	for id, name := range ianaCipherSuites {
+		output[id] = parseCipherSuite(id, name)
+	}
+	return output
+}
+
+// LookupCipherSuite returns what is known about an IANA cipher suite.
+func LookupCipherSuite(id uint16) (CipherSuiteInfo, bool) {
+	info, ok := cipherSuiteCatalog[id]
+	return info, ok
+}
+
+// CipherSuiteCatalog lists every IANA cipher suite, ordered by id.
+func CipherSuiteCatalog() []CipherSuiteInfo {
+	output := make([]CipherSuiteInfo, 0, len(cipherSuiteCatalog))
+	for _, info := range cipherSuiteCatalog {
+		output = append(output, info)
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Id < output[j].Id })
+	return output
+}
+
+// cipherSuiteInfo falls back to the name Go uses for ids IANA has not assigned.
+func cipherSuiteInfo(id uint16) CipherSuiteInfo {
+	if info, ok := LookupCipherSuite(id); ok {
+		return info
+	}
+	return CipherSuiteInfo{Id: id, Name: tls.CipherSuiteName(id)}
+}
+
+func parseCipherSuite(id uint16, name string) CipherSuiteInfo {
+	info := CipherSuiteInfo{Id: id, Name: name, KeyExchange: "any", Authentication: "any"}
+	suite := strings.TrimPrefix(name, "TLS_")
+	if before, after, found := strings.Cut(suite, "_WITH_"); found {
+		parts := keyExchanges[before]
+		info.KeyExchange, info.Authentication = parts[0], parts[1]
+		suite = after
+	}
+
+	tokens := strings.Split(suite, "_")
+	last := tokens[len(tokens)-1]
+	if mac, ok := macs[last]; ok && len(tokens) > 1 {
+		info.Mac = mac
+		info.Cipher = strings.Join(tokens[:len(tokens)-1], "_")
+	} else {
+		// AEAD suites without a hash in the name use the one from their RFC
+		info.Mac = "SHA256"
+		if info.KeyExchange == "GOSTR341112_256" {
+			info.Mac = "GOSTR341112_256"
+		}
+		info.Cipher = suite
+	}
+	if info.Cipher == info.Mac {
+		info.Cipher = "NULL" // TLS_SHA256_SHA256 only protects integrity
+	}
+
+	info.Strength, info.Reason = rateCipherSuite(info)
+	return info
+}
+
+// rateCipherSuite puts each suite in one of four groups. Recommended are the
+// suites in the Mozilla intermediate configuration, secure have forward
+// secrecy and an AEAD cipher, weak are missing one of those, and insecure can
+// be broken or do not protect the traffic at all.
+func rateCipherSuite(info CipherSuiteInfo) (strength string, reason string) {
+	cipher := "_" + info.Cipher + "_"
+	switch {
+	case info.Cipher == "NULL":
+		return CIPHER_INSECURE, "no encryption"
+	case info.Authentication == "anon" || info.Authentication == "NULL":
+		return CIPHER_INSECURE, "no authentication"
+	case strings.HasSuffix(info.KeyExchange, "_EXPORT"):
+		return CIPHER_INSECURE, "export grade"
+	case strings.Contains(cipher, "_RC4_"):
+		return CIPHER_INSECURE, "RC4 is broken"
+	case strings.Contains(cipher, "_RC2_"):
+		return CIPHER_INSECURE, "RC2 is broken"
+	case strings.Contains(cipher, "_DES_") || strings.Contains(cipher, "_DES40_"):
+		return CIPHER_INSECURE, "DES is broken"
+	case strings.Contains(cipher, "_3DES_") || strings.Contains(cipher, "_IDEA_"):
+		return CIPHER_INSECURE, "64 bit block cipher (Sweet32)"
+	case info.Mac == "MD5":
+		return CIPHER_INSECURE, "MD5 MAC"
+	case noForwardSecrecy[info.KeyExchange]:
+		return CIPHER_WEAK, info.KeyExchange + " key exchange has no forward secrecy"
+	case strings.Contains(cipher, "_CBC_"):
+		return CIPHER_WEAK, "CBC mode"
+	case strings.Contains(cipher, "_MAGMA_") || strings.Contains(cipher, "_28147_"):
+		return CIPHER_WEAK, "64 bit block cipher"
+	case strings.Contains(cipher, "_CTR_") || strings.Contains(cipher, "_CNT_"):
+		return CIPHER_WEAK, "not an AEAD cipher"
+	case isMozillaIntermediate(info):
+		return CIPHER_RECOMMENDED, ""
+	}
+	return CIPHER_SECURE, ""
+}
+
+// isMozillaIntermediate is the Mozilla intermediate configuration from
+// https://ssl-config.mozilla.org/ which is every TLS v1.3 suite and the
+// ECDHE and DHE_RSA suites with AES-GCM or ChaCha20-Poly1305.
+func isMozillaIntermediate(info CipherSuiteInfo) bool {
+	switch info.Cipher {
+	case "AES_128_GCM", "AES_256_GCM", "CHACHA20_POLY1305":
+	default:
+		return false
+	}
+	switch info.KeyExchange + "_" + info.Authentication {
+	case "any_any", "ECDHE_ECDSA", "ECDHE_RSA", "DHE_RSA":
+		return true
+	}
+	return false
+}
+
+// description is shown next to the negotiated suite in the text output.
+func (a CipherSuiteInfo) description() string {
+	if a.Strength == "" {
+		return fmt.Sprintf("unknown TLS cipher suite 0x%04X", a.Id)
+	}
+	output := a.Name + " = "
+	if a.KeyExchange != "any" {
+		output += fmt.Sprintf("%s key exchange, %s authentication, ", a.KeyExchange, a.Authentication)
+	}
+	output += fmt.Sprintf("%s cipher, %s hash, %s", a.Cipher, a.Mac, a.Strength)
+	if a.Reason != "" {
+		output += " - " + a.Reason
+	}
+	return output
+}
+
+// keyExchangeGroupName uses the usual names for the NIST curves.
+func keyExchangeGroupName(id tls.CurveID) string {
+	switch id {
+	case 0:
+		return ""
+	case tls.CurveP256:
+		return "P-256"
+	case tls.CurveP384:
+		return "P-384"
+	case tls.CurveP521:
+		return "P-521"
+	}
+	return id.String()
+}
+
+func (a CheckedServer) keyExchangeGroupLine() string {
+	if a.KeyExchangeGroup == "" {
+		return ""
+	}
+	return fmt.Sprintf(" -> key exchange group %s\n", a.KeyExchangeGroup)
+}
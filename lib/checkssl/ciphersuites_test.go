@@ -0,0 +1,107 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func Test_LookupCipherSuite(t *testing.T) {
+	actual, ok := LookupCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA)
+	if !ok {
+		t.Fatal("expected the suite to be in the catalog")
+	}
+	assert(t, strings.Join([]string{actual.KeyExchange, actual.Authentication, actual.Cipher, actual.Mac}, " "), "ECDHE RSA AES_256_CBC SHA1", "")
+
+	tls13, _ := LookupCipherSuite(tls.TLS_CHACHA20_POLY1305_SHA256)
+	assert(t, strings.Join([]string{tls13.KeyExchange, tls13.Authentication, tls13.Cipher, tls13.Mac}, " "), "any any CHACHA20_POLY1305 SHA256", "")
+
+	ccm, _ := LookupCipherSuite(0xC0AE)
+	assert(t, strings.Join([]string{ccm.Name, ccm.KeyExchange, ccm.Authentication, ccm.Cipher, ccm.Mac}, " "), "TLS_ECDHE_ECDSA_WITH_AES_128_CCM_8 ECDHE ECDSA AES_128_CCM_8 SHA256", "")
+
+	_, ok = LookupCipherSuite(0x5600)
+	if ok {
+		t.Error("TLS_FALLBACK_SCSV is not a cipher suite")
+	}
+}
+
+func Test_CipherSuiteCatalog_coversGo(t *testing.T) {
+	for _, suite := range allCipherSuites() {
+		actual, ok := LookupCipherSuite(suite.ID)
+		if !ok || actual.Name != suite.Name {
+			t.Error("expected", suite.Name, "in the catalog, got", actual.Name)
+		}
+	}
+	catalog := CipherSuiteCatalog()
+	if len(catalog) < 300 || catalog[0].Name != "TLS_NULL_WITH_NULL_NULL" {
+		t.Error("expected every IANA suite ordered by id", len(catalog))
+	}
+	for _, suite := range catalog {
+		if suite.Strength == "" || suite.Cipher == "" || suite.KeyExchange == "" {
+			t.Error("expected every part of the suite to be known", suite)
+		}
+	}
+}
+
+func Test_rateCipherSuite(t *testing.T) {
+	expected := map[string]string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":  CIPHER_RECOMMENDED,
+		"TLS_AES_256_GCM_SHA384":                   CIPHER_RECOMMENDED,
+		"TLS_DHE_RSA_WITH_AES_128_GCM_SHA256":      CIPHER_RECOMMENDED,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_CCM":         CIPHER_SECURE,
+		"TLS_ECDHE_ECDSA_WITH_ARIA_128_GCM_SHA256": CIPHER_SECURE,
+		"TLS_RSA_WITH_AES_128_GCM_SHA256":          CIPHER_WEAK,
+		"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":       CIPHER_WEAK,
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":    CIPHER_WEAK,
+		"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":      CIPHER_INSECURE,
+		"TLS_ECDHE_RSA_WITH_RC4_128_SHA":           CIPHER_INSECURE,
+		"TLS_NULL_WITH_NULL_NULL":                  CIPHER_INSECURE,
+		"TLS_RSA_EXPORT_WITH_RC4_40_MD5":           CIPHER_INSECURE,
+		"TLS_DH_anon_WITH_AES_128_GCM_SHA256":      CIPHER_INSECURE,
+		"TLS_SHA256_SHA256":                        CIPHER_INSECURE,
+	}
+	found := 0
+	for _, suite := range CipherSuiteCatalog() {
+		if want, ok := expected[suite.Name]; ok {
+			assert(t, suite.Strength, want, suite.Name)
+			found++
+		}
+	}
+	if found != len(expected) {
+		t.Error("expected to find every suite in the catalog", found)
+	}
+
+	rsa, _ := LookupCipherSuite(tls.TLS_RSA_WITH_AES_128_GCM_SHA256)
+	assert(t, rsa.Reason, "RSA key exchange has no forward secrecy", "")
+}
+
+func Test_getTlsAlgo(t *testing.T) {
+	assert(t, getTlsAlgo(tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA), "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA = ECDHE key exchange, RSA authentication, AES_128_CBC cipher, SHA1 hash, weak - CBC mode", "")
+	assert(t, getTlsAlgo(0x0A0A), "unknown TLS cipher suite 0x0A0A", "")
+}
+
+func Test_CheckServer_keyExchangeGroup(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{
+		Certificates:     []tls.Certificate{leaf.tlsCertificate(ca)},
+		CurvePreferences: []tls.CurveID{tls.CurveP256},
+	}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	assert(t, actual.KeyExchangeGroup, "P-256", "")
+	if actual.CipherSuite == nil || actual.CipherSuite.Strength != CIPHER_RECOMMENDED {
+		t.Error("expected the negotiated suite to be looked up", actual.CipherSuite)
+	}
+	if !strings.Contains(actual.AsString(false), " -> key exchange group P-256\n") {
+		t.Error("expected the group in text output", actual.AsString(false))
+	}
+	if !strings.Contains(actual.AsJson(), `"KeyExchangeGroup":"P-256"`) {
+		t.Error("expected the group in JSON output", actual.AsJson())
+	}
+	columns := strings.Split(actual.AsCsv(), ",")
+	assert(t, strings.Join(columns[7:10], ","), actual.CipherSuite.Name+",recommended,P-256", "")
+}
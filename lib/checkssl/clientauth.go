@@ -0,0 +1,113 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ClientAuth is recorded when the server sends a CertificateRequest, which
+// means the endpoint is protected by mutual TLS.
+type ClientAuth struct {
+	AcceptableCAs []string `json:",omitempty"`
+	Sent          bool
+	Certificate   string `json:",omitempty"`
+}
+
+// SetClientCertificate sends cert when the server asks for a client certificate.
+func (a *CheckSSL) SetClientCertificate(cert tls.Certificate) {
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	a.clientCert = &cert
+}
+
+// LoadClientCertificate loads a client certificate from a PEM certificate and
+// key, or from a PKCS#12 file with password. keyFile can be empty when the key
+// is in the same PEM file as the certificate.
+func (a *CheckSSL) LoadClientCertificate(certFile string, keyFile string, password string) error {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+
+	var cert tls.Certificate
+	if block, _ := pem.Decode(data); block == nil {
+		cert, err = parsePkcs12(data, password)
+	} else {
+		keyData := data
+		if keyFile != "" {
+			keyData, err = os.ReadFile(keyFile)
+			if err != nil {
+				return err
+			}
+		}
+		cert, err = tls.X509KeyPair(data, keyData)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", certFile, err)
+	}
+	a.SetClientCertificate(cert)
+	return nil
+}
+
+// clientCertificate records the CertificateRequest in output, and answers it
+// with the client certificate if there is one. Without one the handshake
+// carries on with an empty certificate, so servers that only ask for one
+// can still be checked.
+func (a *CheckSSL) clientCertificate(output *CheckedServer) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(request *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		output.ClientAuth = &ClientAuth{AcceptableCAs: distinguishedNames(request.AcceptableCAs)}
+		if a.clientCert == nil {
+			return &tls.Certificate{}, nil
+		}
+		output.ClientAuth.Sent = true
+		if a.clientCert.Leaf != nil {
+			output.ClientAuth.Certificate = a.clientCert.Leaf.Subject.String()
+		}
+		return a.clientCert, nil
+	}
+}
+
+// tlsConfig is used for every handshake with the target.
+func (a *CheckSSL) tlsConfig(serverName string, insecure bool, output *CheckedServer) *tls.Config {
+	return &tls.Config{
+		ServerName:           serverName,
+		InsecureSkipVerify:   insecure,
+		RootCAs:              a.rootCAs,
+		GetClientCertificate: a.clientCertificate(output),
+	}
+}
+
+func distinguishedNames(names [][]byte) (output []string) {
+	for _, der := range names {
+		var sequence pkix.RDNSequence
+		_, err := asn1.Unmarshal(der, &sequence)
+		if err != nil {
+			continue
+		}
+		var name pkix.Name
+		name.FillFromRDNSequence(&sequence)
+		output = append(output, name.String())
+	}
+	return
+}
+
+func (a CheckedServer) clientAuthLine(color terminalColors) string {
+	if a.ClientAuth == nil {
+		return ""
+	}
+	accepts := "any CA"
+	if len(a.ClientAuth.AcceptableCAs) > 0 {
+		accepts = strings.Join(a.ClientAuth.AcceptableCAs, "; ")
+	}
+	if a.ClientAuth.Sent {
+		return fmt.Sprintf(" -> mTLS: server requested a client certificate from %s, sent %s\n", accepts, a.ClientAuth.Certificate)
+	}
+	return fmt.Sprintf(" -> %smTLS: server requested a client certificate from %s, none was sent%s\n", color.yellow, accepts, color.noColor)
+}
@@ -0,0 +1,127 @@
+package checkssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func startMtlsServer(t *testing.T, clientCa *testCertificate) string {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	clientCas := x509.NewCertPool()
+	clientCas.AddCert(clientCa.Cert)
+	return startTestServer(t, serveTls(&tls.Config{
+		Certificates: []tls.Certificate{leaf.tlsCertificate()},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    clientCas,
+	}))
+}
+
+func Test_parsePkcs12(t *testing.T) {
+	for _, name := range []string{"client.p12", "client-3des.p12"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := parsePkcs12(data, "hunter2")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		assert(t, actual.Leaf.Subject.CommonName, "test client", name)
+		if len(actual.Certificate) != 2 {
+			t.Error(name, "expected the client certificate and its CA, got", len(actual.Certificate))
+		}
+		if _, ok := actual.PrivateKey.(*ecdsa.PrivateKey); !ok {
+			t.Error(name, "expected an ECDSA private key")
+		}
+
+		_, err = parsePkcs12(data, "wrong")
+		if err == nil || err.Error() != "PKCS#12 password is incorrect" {
+			t.Error(name, "expected a wrong password to be reported, got", err)
+		}
+	}
+}
+
+func Test_CheckServer_clientCertificateRequested(t *testing.T) {
+	address := startMtlsServer(t, newTestCA(t, "Client CA"))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if actual.ClientAuth == nil || actual.ClientAuth.Sent {
+		t.Fatal("expected the CertificateRequest to be recorded without sending a certificate", actual.ClientAuth)
+	}
+	assert(t, strings.Join(actual.ClientAuth.AcceptableCAs, " "), "CN=Client CA", "")
+	if !strings.Contains(actual.AsString(false), " -> mTLS: server requested a client certificate from CN=Client CA, none was sent\n") {
+		t.Error("expected the request in text output", actual.AsString(false))
+	}
+	if !strings.Contains(actual.AsJson(), `"ClientAuth":{"AcceptableCAs":["CN=Client CA"],"Sent":false}`) {
+		t.Error("expected the request in JSON output", actual.AsJson())
+	}
+}
+
+func Test_CheckServer_clientCertificateNotRequested(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if actual.ClientAuth != nil {
+		t.Error("did not expect a CertificateRequest", actual.ClientAuth)
+	}
+}
+
+func Test_LoadClientCertificate_pem(t *testing.T) {
+	clientCa := newTestCA(t, "Client CA")
+	client := newTestLeaf(t, clientCa, "test client")
+	address := startMtlsServer(t, clientCa)
+
+	key, err := x509.MarshalPKCS8PrivateKey(client.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile := writeTestCaFile(t, dir, "client.pem", client)
+	keyFile := filepath.Join(dir, "client.key")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	err = checker.LoadClientCertificate(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if actual.ClientAuth == nil || !actual.ClientAuth.Sent {
+		t.Fatal("expected the client certificate to be sent", actual.ClientAuth)
+	}
+	assert(t, actual.ClientAuth.Certificate, "CN=test client", "")
+	if !strings.Contains(actual.AsString(false), " -> mTLS: server requested a client certificate from CN=Client CA, sent CN=test client\n") {
+		t.Error("expected the sent certificate in text output", actual.AsString(false))
+	}
+}
+
+func Test_LoadClientCertificate_pkcs12(t *testing.T) {
+	checker := NewCheckSSL()
+	err := checker.LoadClientCertificate(filepath.Join("testdata", "client.p12"), "", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, checker.clientCert.Leaf.Subject.CommonName, "test client", "")
+
+	err = checker.LoadClientCertificate(filepath.Join("testdata", "client.p12"), "", "")
+	assert(t, err.Error(), filepath.Join("testdata", "client.p12")+": PKCS#12 password is incorrect", "")
+}
@@ -0,0 +1,254 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// keyExchangeGroups are the groups a client profile can list, by the names
+// keyExchangeGroupName gives them.
+var keyExchangeGroups = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521, tls.X25519MLKEM768}
+
+var (
+	bundledClientProfiles     []clientProfile
+	bundledClientProfilesErr  error
+	bundledClientProfilesOnce sync.Once
+)
+
+// bundledClientProfileList is the set of clients simulated by default.
+//
+//go:embed client_profiles.json
+var bundledClientProfileList []byte
+
+// ClientProfile is one client in a profile file, see client_profiles.json.
+// Cipher suites use their IANA names, in the order the client offers them.
+type ClientProfile struct {
+	Name         string   `json:"name"`
+	MinVersion   string   `json:"min_version"`
+	MaxVersion   string   `json:"max_version"`
+	CipherSuites []string `json:"cipher_suites"`
+	Curves       []string `json:"curves"`
+	Alpn         []string `json:"alpn,omitempty"`
+}
+
+type clientProfileList struct {
+	Profiles []ClientProfile `json:"profiles"`
+}
+
+// clientProfile is a ClientProfile with every name looked up. The suites Go
+// does not implement are kept apart in missing, since crypto/tls would drop
+// them from the offer without saying so.
+type clientProfile struct {
+	name       string
+	minVersion uint16
+	maxVersion uint16
+	suites     []uint16
+	missing    []string
+	curves     []tls.CurveID
+	alpn       []string
+}
+
+// ClientSimulation is what one client profile negotiated with the server. A
+// simulation is Approximate when the profile has cipher suites Go can not
+// offer, which are listed in MissingCipherSuites.
+type ClientSimulation struct {
+	Client              string
+	Connected           bool
+	Version             uint16   `json:",omitempty"`
+	CipherSuite         string   `json:",omitempty"`
+	KeyExchangeGroup    string   `json:",omitempty"`
+	Alpn                string   `json:",omitempty"`
+	Err                 string   `json:",omitempty"`
+	Approximate         bool     `json:",omitempty"`
+	MissingCipherSuites []string `json:",omitempty"`
+}
+
+// SetSimulateClients makes a handshake as each client profile to show which
+// clients can connect and what they would negotiate.
+func (a *CheckSSL) SetSimulateClients(enable bool) {
+	a.simulateClients = enable
+}
+
+// AddClientProfiles adds the profiles in a file in the same format as
+// client_profiles.json to the bundled ones. A profile with the same name as
+// a bundled one replaces it.
+func (a *CheckSSL) AddClientProfiles(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	added, err := parseClientProfiles(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	profiles, err := a.knownClientProfiles()
+	if err != nil {
+		return err
+	}
+	profiles = append([]clientProfile{}, profiles...)
+	for _, profile := range added {
+		replaced := false
+		for i := range profiles {
+			if profiles[i].name == profile.name {
+				profiles[i], replaced = profile, true
+			}
+		}
+		if !replaced {
+			profiles = append(profiles, profile)
+		}
+	}
+	a.clientProfiles = profiles
+	return nil
+}
+
+func (a *CheckSSL) knownClientProfiles() ([]clientProfile, error) {
+	if a.clientProfiles != nil {
+		return a.clientProfiles, nil
+	}
+	bundledClientProfilesOnce.Do(func() {
+		bundledClientProfiles, bundledClientProfilesErr = parseClientProfiles(bundledClientProfileList)
+	})
+	return bundledClientProfiles, bundledClientProfilesErr
+}
+
+func parseClientProfiles(data []byte) ([]clientProfile, error) {
+	var list clientProfileList
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client profiles: %w", err)
+	}
+
+	suitesByName := map[string]uint16{}
+	for _, suite := range CipherSuiteCatalog() {
+		suitesByName[suite.Name] = suite.Id
+	}
+	implemented := map[uint16]bool{}
+	for _, suite := range allCipherSuites() {
+		implemented[suite.ID] = true
+	}
+
+	var output []clientProfile
+	for _, entry := range list.Profiles {
+		profile := clientProfile{name: entry.Name, alpn: entry.Alpn}
+		profile.minVersion, err = ParseTlsVersion(entry.MinVersion)
+		if err != nil {
+			return nil, fmt.Errorf("client %q: %w", entry.Name, err)
+		}
+		profile.maxVersion, err = ParseTlsVersion(entry.MaxVersion)
+		if err != nil {
+			return nil, fmt.Errorf("client %q: %w", entry.Name, err)
+		}
+		for _, name := range entry.CipherSuites {
+			id, ok := suitesByName[name]
+			if !ok {
+				return nil, fmt.Errorf("client %q: unknown cipher suite %s", entry.Name, name)
+			}
+			if !implemented[id] {
+				profile.missing = append(profile.missing, name)
+				continue
+			}
+			profile.suites = append(profile.suites, id)
+		}
+		for _, name := range entry.Curves {
+			curve, ok := parseKeyExchangeGroup(name)
+			if !ok {
+				return nil, fmt.Errorf("client %q: unknown curve %s", entry.Name, name)
+			}
+			profile.curves = append(profile.curves, curve)
+		}
+		output = append(output, profile)
+	}
+	return output, nil
+}
+
+func parseKeyExchangeGroup(name string) (tls.CurveID, bool) {
+	for _, group := range keyExchangeGroups {
+		if keyExchangeGroupName(group) == name {
+			return group, true
+		}
+	}
+	return 0, false
+}
+
+// checkClients makes one handshake per profile. Go only offers the suites it
+// implements and always picks its own TLS v1.3 suites, so a profile is only
+// as close to the real client as Go allows.
+func (a *CheckSSL) checkClients(output *CheckedServer, serverName string, address string, upgrade tlsUpgrade) {
+	profiles, err := a.knownClientProfiles()
+	if err != nil {
+		output.ClientSimulations = []ClientSimulation{{Client: "all", Err: err.Error()}}
+		return
+	}
+
+	output.ClientSimulations = nil
+	for _, profile := range profiles {
+		config := a.tlsConfig(serverName, true, &CheckedServer{})
+		config.MinVersion, config.MaxVersion = profile.minVersion, profile.maxVersion
+		config.CipherSuites = profile.suites
+		config.CurvePreferences = profile.curves
+		config.NextProtos = profile.alpn
+
+		result := ClientSimulation{Client: profile.name, Approximate: len(profile.missing) > 0, MissingCipherSuites: profile.missing}
+		if len(config.CipherSuites) == 0 && len(profile.missing) > 0 && profile.maxVersion < tls.VersionTLS13 {
+			// an empty list would make Go offer its own defaults instead
+			result.Err = "Go can not offer any of its cipher suites"
+			output.ClientSimulations = append(output.ClientSimulations, result)
+			continue
+		}
+		state, _, err := a.tlsHandshake(config, address, upgrade)
+		if err != nil {
+			result.Err = err.Error()
+		} else {
+			result.Connected = true
+			result.Version = state.Version
+			result.CipherSuite = cipherSuiteInfo(state.CipherSuite).Name
+			result.KeyExchangeGroup = keyExchangeGroupName(state.CurveID)
+			result.Alpn = state.NegotiatedProtocol
+		}
+		output.ClientSimulations = append(output.ClientSimulations, result)
+	}
+}
+
+// clientSimulationLines pads each column to its widest value. A failed
+// client shows its error in place of the negotiated values, and an
+// approximate one is marked with a * and explained under the table.
+func (a CheckedServer) clientSimulationLines() string {
+	if len(a.ClientSimulations) == 0 {
+		return ""
+	}
+	rows := [][]string{{"Client", "Result", "Version", "Cipher Suite", "Group", "ALPN"}}
+	notes := ""
+	for _, result := range a.ClientSimulations {
+		client := result.Client
+		if result.Approximate {
+			client += "*"
+			notes += fmt.Sprintf("      * %s is approximate, Go can not offer %d of its cipher suites\n", result.Client, len(result.MissingCipherSuites))
+		}
+		if result.Connected {
+			rows = append(rows, []string{client, "connected", tls.VersionName(result.Version), result.CipherSuite, result.KeyExchangeGroup, result.Alpn})
+		} else {
+			rows = append(rows, []string{client, "failed", result.Err})
+		}
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row[:len(row)-1] {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	output := " -> client simulation:\n"
+	for _, row := range rows {
+		line := "     "
+		for i, cell := range row[:len(row)-1] {
+			line += fmt.Sprintf(" %-*s ", widths[i], cell)
+		}
+		output += strings.TrimRight(line+" "+row[len(row)-1], " ") + "\n"
+	}
+	return output + notes
+}
@@ -0,0 +1,140 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testClientProfiles = `{"profiles": [
+	{"name": "Modern", "min_version": "1.2", "max_version": "1.3", "cipher_suites": ["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"], "curves": ["X25519", "P-256"], "alpn": ["h2", "http/1.1"]},
+	{"name": "TLS 1.2 only", "min_version": "1.2", "max_version": "1.2", "cipher_suites": ["TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"], "curves": ["P-384"]},
+	{"name": "Old", "min_version": "1.0", "max_version": "1.1", "cipher_suites": ["TLS_DHE_RSA_WITH_AES_128_CBC_SHA", "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"], "curves": ["P-256"]},
+	{"name": "Chrome 131", "min_version": "1.3", "max_version": "1.3", "cipher_suites": [], "curves": ["X25519MLKEM768"], "alpn": ["h2"]}
+]}`
+
+func writeTestClientProfiles(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	err := os.WriteFile(path, []byte(data), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_parseClientProfiles_bundled(t *testing.T) {
+	checker := NewCheckSSL()
+	profiles, err := checker.knownClientProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, profile := range profiles {
+		names = append(names, profile.name)
+	}
+	for _, expected := range []string{"Android 4.4.2", "Java 8u161", "OpenSSL 1.0.2", "Chrome 131"} {
+		if !strings.Contains(strings.Join(names, ", "), expected) {
+			t.Error("expected a bundled profile for", expected, names)
+		}
+	}
+	for _, profile := range profiles {
+		if profile.name == "Android 4.4.2" && !strings.Contains(strings.Join(profile.missing, " "), "TLS_RSA_WITH_RC4_128_MD5") {
+			t.Error("expected the suites Go can not offer to be kept apart", profile.missing)
+		}
+		if profile.name == "Chrome 131" && len(profile.missing) != 0 {
+			t.Error("expected Go to offer every Chrome suite", profile.missing)
+		}
+	}
+}
+
+func Test_parseClientProfiles_unknownNames(t *testing.T) {
+	_, err := parseClientProfiles([]byte(`{"profiles": [{"name": "Typo", "min_version": "1.2", "max_version": "1.3", "cipher_suites": ["TLS_ECDHE_RSA_WITH_AES_128_GCM"]}]}`))
+	if err == nil || err.Error() != `client "Typo": unknown cipher suite TLS_ECDHE_RSA_WITH_AES_128_GCM` {
+		t.Error("expected the unknown suite to be named", err)
+	}
+	_, err = parseClientProfiles([]byte(`{"profiles": [{"name": "Typo", "min_version": "1.2", "max_version": "1.3", "curves": ["P-255"]}]}`))
+	if err == nil || err.Error() != `client "Typo": unknown curve P-255` {
+		t.Error("expected the unknown curve to be named", err)
+	}
+}
+
+func Test_AddClientProfiles(t *testing.T) {
+	checker := NewCheckSSL()
+	bundled, _ := checker.knownClientProfiles()
+	err := checker.AddClientProfiles(writeTestClientProfiles(t, testClientProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, _ := checker.knownClientProfiles()
+	if len(profiles) != len(bundled)+3 {
+		t.Error("expected three new profiles and Chrome 131 to be replaced", len(profiles))
+	}
+	for _, profile := range profiles {
+		if profile.name == "Chrome 131" && profile.minVersion != tls.VersionTLS13 {
+			t.Error("expected the added Chrome 131 to replace the bundled one")
+		}
+	}
+	fresh := NewCheckSSL()
+	freshProfiles, _ := fresh.knownClientProfiles()
+	if len(freshProfiles) != len(bundled) {
+		t.Error("adding profiles should not change the bundled list")
+	}
+}
+
+func Test_CheckServer_simulateClients(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{
+		Certificates: []tls.Certificate{leaf.tlsCertificate(ca)},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+	}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetSimulateClients(true)
+	checker.clientProfiles = []clientProfile{}
+	err := checker.AddClientProfiles(writeTestClientProfiles(t, testClientProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if len(actual.ClientSimulations) != 4 {
+		t.Fatal("expected a result for every profile", actual.ClientSimulations)
+	}
+	modern, tls12, old := actual.ClientSimulations[0], actual.ClientSimulations[1], actual.ClientSimulations[2]
+	if !modern.Connected || modern.Version != tls.VersionTLS13 || modern.KeyExchangeGroup != "X25519" || modern.Alpn != "h2" {
+		t.Error("expected the modern client to get TLS v1.3 with X25519 and h2", modern)
+	}
+	if !tls12.Connected || tls12.CipherSuite != "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384" || tls12.KeyExchangeGroup != "P-384" {
+		t.Error("expected the TLS v1.2 client to get its only suite and curve", tls12)
+	}
+	if old.Connected || old.Err == "" {
+		t.Error("expected the old client to fail", old)
+	}
+	if !old.Approximate || strings.Join(old.MissingCipherSuites, " ") != "TLS_DHE_RSA_WITH_AES_128_CBC_SHA" || modern.Approximate {
+		t.Error("expected only the old client to be approximate", old, modern)
+	}
+	if !actual.Passed {
+		t.Error("a client that can not connect should not fail the check", actual.Err)
+	}
+
+	text := actual.AsString(false)
+	for _, expected := range []string{
+		" -> client simulation:\n      Client        Result     Version  Cipher Suite",
+		"\n      TLS 1.2 only  connected  TLS 1.2  TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384  P-384\n",
+		"\n      Old*          failed     " + old.Err + "\n",
+		"\n      * Old is approximate, Go can not offer 1 of its cipher suites\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Error("expected text output to include", expected, text)
+		}
+	}
+	if !strings.Contains(actual.AsJson(), `"ClientSimulations":[{"Client":"Modern","Connected":true,"Version":772,`) {
+		t.Error("expected the simulations as an array in JSON", actual.AsJson())
+	}
+}
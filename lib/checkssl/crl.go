@@ -0,0 +1,257 @@
+package checkssl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// some CAs publish CRLs that are tens of megabytes
+	crlMaxBytes = 64 << 20
+
+	// how long a CRL without a next update is used before it is downloaded again
+	crlDefaultLifetime = 24 * time.Hour
+)
+
+type localCrl struct {
+	path string
+	list *x509.RevocationList
+}
+
+// crlCache keeps downloaded CRLs in memory so concurrent checks of hosts with
+// the same issuer only download each CRL once.
+type crlCache struct {
+	mutex     sync.Mutex
+	downloads map[string]*crlDownload
+}
+
+type crlDownload struct {
+	done    chan struct{}
+	list    *x509.RevocationList
+	fetched time.Time
+	err     error
+}
+
+// SetCrl turns on checking each certificate against the CRLs listed in its
+// CRL distribution points.
+func (a *CheckSSL) SetCrl(enable bool) {
+	a.crl = enable
+}
+
+// SetCrlCacheDir sets where downloaded CRLs are kept until their next update,
+// or for a day when they have none. An empty dir turns the disk cache off.
+func (a *CheckSSL) SetCrlCacheDir(dir string) {
+	a.crlCacheDir = dir
+}
+
+// AddCrlFile loads a PEM or DER encoded CRL. Certificates from the CRL's
+// issuer are checked against it instead of downloading a CRL, for networks
+// without internet access.
+func (a *CheckSSL) AddCrlFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	list, err := parseCrl(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	a.crlFiles = append(a.crlFiles, localCrl{path: path, list: list})
+	return nil
+}
+
+func defaultCrlCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "checkssl", "crl")
+}
+
+// checkCrl looks up cert in a CRL from its issuer, returning nil when there
+// is no CRL to check against.
+func (a *CheckSSL) checkCrl(cert *x509.Certificate, issuer *x509.Certificate) *RevocationStatus {
+	if issuer == nil {
+		if len(cert.CRLDistributionPoints) == 0 {
+			return nil
+		}
+		return &RevocationStatus{Status: REVOCATION_UNKNOWN, Source: cert.CRLDistributionPoints[0], Err: "issuer certificate was not presented"}
+	}
+
+	for _, local := range a.crlFiles {
+		if bytes.Equal(local.list.RawIssuer, issuer.RawSubject) && local.list.CheckSignatureFrom(issuer) == nil {
+			return crlStatus(local.list, cert, local.path)
+		}
+	}
+
+	var output *RevocationStatus
+	for _, distributionPoint := range cert.CRLDistributionPoints {
+		if !strings.HasPrefix(distributionPoint, "http://") && !strings.HasPrefix(distributionPoint, "https://") {
+			continue
+		}
+		output = &RevocationStatus{Status: REVOCATION_UNKNOWN, Source: distributionPoint}
+		list, err := a.downloadCrl(distributionPoint)
+		if err != nil {
+			output.Err = err.Error()
+			continue
+		}
+		err = list.CheckSignatureFrom(issuer)
+		if err != nil {
+			output.Err = "CRL signature is invalid: " + err.Error()
+			continue
+		}
+		return crlStatus(list, cert, distributionPoint)
+	}
+	return output
+}
+
+func crlStatus(list *x509.RevocationList, cert *x509.Certificate, source string) *RevocationStatus {
+	output := &RevocationStatus{
+		Status:     REVOCATION_GOOD,
+		ThisUpdate: list.ThisUpdate,
+		NextUpdate: list.NextUpdate,
+		Source:     source,
+	}
+	for _, entry := range list.RevokedCertificateEntries {
+		if entry.SerialNumber != nil && entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			output.Status = REVOCATION_REVOKED
+			output.RevokedAt = entry.RevocationTime
+			output.Reason = crlReasons[entry.ReasonCode]
+			break
+		}
+	}
+	if !list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+		output.Err = "CRL is stale, next update was " + list.NextUpdate.Format(dateLayout)
+	}
+	return output
+}
+
+// downloadCrl returns the CRL at crlUrl from memory, then the disk cache,
+// and only downloads it when neither has a copy that is still current. A
+// failed download is remembered for the rest of the run, so an unreachable
+// CRL does not cost a timeout for every certificate that lists it.
+func (a *CheckSSL) downloadCrl(crlUrl string) (*x509.RevocationList, error) {
+	if a.crls == nil {
+		list, _, err := a.loadCrl(crlUrl)
+		return list, err
+	}
+
+	a.crls.mutex.Lock()
+	download, found := a.crls.downloads[crlUrl]
+	if found {
+		a.crls.mutex.Unlock()
+		<-download.done
+		if download.err != nil || isCurrentCrl(download.list, download.fetched) {
+			return download.list, download.err
+		}
+		a.crls.mutex.Lock()
+		if a.crls.downloads[crlUrl] == download {
+			delete(a.crls.downloads, crlUrl)
+		}
+		a.crls.mutex.Unlock()
+		return a.downloadCrl(crlUrl)
+	}
+	download = &crlDownload{done: make(chan struct{})}
+	a.crls.downloads[crlUrl] = download
+	a.crls.mutex.Unlock()
+
+	download.list, download.fetched, download.err = a.loadCrl(crlUrl)
+	close(download.done)
+	return download.list, download.err
+}
+
+// loadCrl returns the CRL at crlUrl and when it was downloaded, which for
+// the disk cache is when the file was written.
+func (a *CheckSSL) loadCrl(crlUrl string) (*x509.RevocationList, time.Time, error) {
+	cacheFile := a.crlCacheFile(crlUrl)
+	if cacheFile != "" {
+		data, err := os.ReadFile(cacheFile)
+		info, statErr := os.Stat(cacheFile)
+		if err == nil && statErr == nil {
+			list, err := parseCrl(data)
+			if err == nil && isCurrentCrl(list, info.ModTime()) {
+				return list, info.ModTime(), nil
+			}
+		}
+	}
+
+	fetched := time.Now()
+	data, err := a.fetch(http.MethodGet, crlUrl, "", nil, crlMaxBytes)
+	if err != nil {
+		return nil, fetched, err
+	}
+	list, err := parseCrl(data)
+	if err != nil {
+		return nil, fetched, err
+	}
+	if cacheFile != "" {
+		// the cache is only an optimization, so failing to write it is not an error
+		_ = writeCacheFile(cacheFile, data)
+	}
+	return list, fetched, nil
+}
+
+// isCurrentCrl is true until the CRL's next update. A CRL without one is
+// used for crlDefaultLifetime after it was fetched.
+func isCurrentCrl(list *x509.RevocationList, fetched time.Time) bool {
+	expires := list.NextUpdate
+	if expires.IsZero() {
+		expires = fetched.Add(crlDefaultLifetime)
+	}
+	return time.Now().Before(expires)
+}
+
+func (a *CheckSSL) crlCacheFile(crlUrl string) string {
+	if a.crlCacheDir == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(crlUrl))
+	return filepath.Join(a.crlCacheDir, hex.EncodeToString(hash[:])+".crl")
+}
+
+// writeCacheFile writes through a temporary file so that a concurrent run
+// never reads half of a CRL.
+func writeCacheFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func parseCrl(data []byte) (*x509.RevocationList, error) {
+	block, _ := pem.Decode(data)
+	if block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("expected an X509 CRL but found %s", block.Type)
+		}
+		data = block.Bytes
+	}
+	list, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRL: %w", err)
+	}
+	return list, nil
+}
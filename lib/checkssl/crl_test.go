@@ -0,0 +1,281 @@
+package checkssl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestCrl signs a CRL with ca listing revoked as revoked for key compromise.
+func newTestCrl(t *testing.T, ca *testCertificate, nextUpdate time.Time, revoked ...*testCertificate) []byte {
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, cert := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   cert.Cert.SerialNumber,
+			RevocationTime: time.Now().Add(-2 * time.Hour),
+			ReasonCode:     1,
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.Cert, ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// newTestCrlWithoutNextUpdate re-signs a CRL with its nextUpdate removed,
+// which x509.CreateRevocationList can not do since the field is optional.
+func newTestCrlWithoutNextUpdate(t *testing.T, ca *testCertificate) []byte {
+	list, err := x509.ParseRevocationList(newTestCrl(t, ca, time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tbs asn1.RawValue
+	_, err = asn1.Unmarshal(list.RawTBSRevocationList, &tbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// version, signature algorithm, issuer, thisUpdate, then the nextUpdate to drop
+	var fields []byte
+	for rest, i := tbs.Bytes, 0; len(rest) > 0; i++ {
+		var field asn1.RawValue
+		rest, err = asn1.Unmarshal(rest, &field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != 4 {
+			fields = append(fields, field.FullBytes...)
+		}
+	}
+	tbsDer, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(tbsDer)
+	signature, err := ca.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		Tbs       asn1.RawValue
+		Algorithm pkix.AlgorithmIdentifier
+		Signature asn1.BitString
+	}{asn1.RawValue{FullBytes: tbsDer}, pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}, asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func newTestLeafWithCrl(t *testing.T, ca *testCertificate, crlUrl string) *testCertificate {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		CRLDistributionPoints: []string{crlUrl},
+	}, ca)
+}
+
+// startCrlServer serves crl and counts how many times it was downloaded.
+func startCrlServer(t *testing.T, crl *[]byte) (*httptest.Server, *int32) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		_, _ = w.Write(*crl)
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+func testCrlCheck(t *testing.T, checker CheckSSL, leaf *testCertificate, ca *testCertificate) CheckedServer {
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate(ca)}}))
+	checker.SetTimeout(2)
+	checker.SetCrl(true)
+	return checker.CheckServer("tls://"+address, true)
+}
+
+func Test_CheckServer_crlRevoked(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	var crl []byte
+	server, _ := startCrlServer(t, &crl)
+	leaf := newTestLeafWithCrl(t, ca, server.URL+"/ca.crl")
+	crl = newTestCrl(t, ca, time.Now().Add(24*time.Hour), leaf)
+
+	checker := NewCheckSSL()
+	checker.SetCrlCacheDir("")
+	actual := testCrlCheck(t, checker, leaf, ca)
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_REVOKED {
+		t.Fatal("expected a certificate on the CRL to fail with RETURNCODE_REVOKED, got", actual.ExitCode, actual.Certs[0].Crl)
+	}
+	assert(t, actual.Certs[0].Crl.Reason, "keyCompromise", "")
+	if actual.Certs[1].Crl != nil {
+		t.Error("the self signed root has nothing to check against")
+	}
+	if !strings.Contains(actual.AsString(false), "was REVOKED on") {
+		t.Error("expected the revocation in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_crlCache(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	var crl []byte
+	server, downloads := startCrlServer(t, &crl)
+	leaf := newTestLeafWithCrl(t, ca, server.URL+"/ca.crl")
+	crl = newTestCrl(t, ca, time.Now().Add(24*time.Hour))
+	cacheDir := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		// a new checker each time, so only the disk cache is shared
+		checker := NewCheckSSL()
+		checker.SetCrlCacheDir(cacheDir)
+		actual := testCrlCheck(t, checker, leaf, ca)
+		if !actual.Passed || actual.Certs[0].Crl.Status != REVOCATION_GOOD {
+			t.Fatal("expected the certificate to pass the CRL check", actual.Certs[0].Crl)
+		}
+	}
+	if atomic.LoadInt32(downloads) != 1 {
+		t.Error("expected the cached CRL to be used, downloads =", atomic.LoadInt32(downloads))
+	}
+}
+
+func Test_CheckServer_crlWithoutNextUpdate(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	var crl []byte
+	server, downloads := startCrlServer(t, &crl)
+	leaf := newTestLeafWithCrl(t, ca, server.URL+"/ca.crl")
+	crl = newTestCrlWithoutNextUpdate(t, ca)
+	cacheDir := t.TempDir()
+
+	checker := NewCheckSSL()
+	checker.SetCrlCacheDir(cacheDir)
+	for i := 0; i < 2; i++ {
+		actual := testCrlCheck(t, checker, leaf, ca)
+		if !actual.Passed || actual.Certs[0].Crl.Status != REVOCATION_GOOD || !actual.Certs[0].Crl.NextUpdate.IsZero() {
+			t.Fatal("expected a CRL without a next update to be checked", actual.Err, actual.Certs[0].Crl)
+		}
+	}
+	fresh := NewCheckSSL()
+	fresh.SetCrlCacheDir(cacheDir)
+	testCrlCheck(t, fresh, leaf, ca)
+	if atomic.LoadInt32(downloads) != 1 {
+		t.Error("expected a CRL without a next update to be downloaded once, downloads =", atomic.LoadInt32(downloads))
+	}
+
+	list, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isCurrentCrl(list, time.Now().Add(-crlDefaultLifetime-time.Minute)) {
+		t.Error("expected a CRL without a next update to expire after crlDefaultLifetime")
+	}
+}
+
+func Test_downloadCrl_failureIsCached(t *testing.T) {
+	crl := []byte("not a CRL")
+	server, downloads := startCrlServer(t, &crl)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetCrlCacheDir("")
+	for i := 0; i < 3; i++ {
+		_, err := checker.downloadCrl(server.URL)
+		if err == nil {
+			t.Fatal("expected the broken CRL to fail")
+		}
+	}
+	if atomic.LoadInt32(downloads) != 1 {
+		t.Error("expected a failed download to be tried once, got", atomic.LoadInt32(downloads))
+	}
+}
+
+func Test_CheckServer_crlStaleCacheIsReplaced(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	var crl []byte
+	server, downloads := startCrlServer(t, &crl)
+	leaf := newTestLeafWithCrl(t, ca, server.URL+"/ca.crl")
+	crl = newTestCrl(t, ca, time.Now().Add(24*time.Hour), leaf)
+
+	checker := NewCheckSSL()
+	checker.SetCrlCacheDir(t.TempDir())
+	err := writeCacheFile(checker.crlCacheFile(server.URL+"/ca.crl"), newTestCrl(t, ca, time.Now().Add(-time.Minute)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := testCrlCheck(t, checker, leaf, ca)
+	if actual.ExitCode != RETURNCODE_REVOKED || atomic.LoadInt32(downloads) != 1 {
+		t.Error("expected an expired cached CRL to be downloaded again", actual.ExitCode, atomic.LoadInt32(downloads))
+	}
+}
+
+func Test_CheckServer_crlBadSignature(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	var crl []byte
+	server, _ := startCrlServer(t, &crl)
+	leaf := newTestLeafWithCrl(t, ca, server.URL+"/ca.crl")
+	crl = newTestCrl(t, newTestCA(t, "Test CA"), time.Now().Add(24*time.Hour), leaf)
+
+	checker := NewCheckSSL()
+	checker.SetCrlCacheDir("")
+	actual := testCrlCheck(t, checker, leaf, ca)
+
+	if !actual.Passed || actual.Certs[0].Crl.Status != REVOCATION_UNKNOWN || !strings.Contains(actual.Certs[0].Crl.Err, "signature is invalid") {
+		t.Error("expected a CRL signed by another key to be ignored with a warning", actual.Certs[0].Crl)
+	}
+}
+
+func Test_AddCrlFile(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeafWithCrl(t, ca, "http://127.0.0.1:1/unreachable.crl")
+	path := filepath.Join(t.TempDir(), "ca.crl")
+	crl := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: newTestCrl(t, ca, time.Now().Add(24*time.Hour), leaf)})
+	err := os.WriteFile(path, crl, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewCheckSSL()
+	checker.SetCrlCacheDir("")
+	err = checker.AddCrlFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := testCrlCheck(t, checker, leaf, ca)
+
+	if actual.ExitCode != RETURNCODE_REVOKED {
+		t.Error("expected the local CRL to be used instead of the distribution point", actual.Certs[0].Crl)
+	}
+	assert(t, actual.Certs[0].Crl.Source, path, "")
+}
+
+func Test_AddCrlFile_invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.crl")
+	_ = os.WriteFile(path, []byte("not a crl"), 0644)
+
+	checker := NewCheckSSL()
+	if checker.AddCrlFile(path) == nil {
+		t.Error("expected an error for a file that is not a CRL")
+	}
+}
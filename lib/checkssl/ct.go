@@ -0,0 +1,551 @@
+package checkssl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	_ "embed"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:generate curl -sSf -o ct_logs.json https://www.gstatic.com/ct/log_list/v3/log_list.json
+
+const (
+	SCT_EMBEDDED = "embedded"
+	SCT_TLS      = "tls"
+	SCT_OCSP     = "ocsp"
+
+	ctLogUsable    = "usable"
+	ctLogQualified = "qualified"
+	ctLogReadOnly  = "readonly"
+	ctLogRetired   = "retired"
+
+	sctVersion1             = 0
+	sctCertificateTimestamp = 0
+	sctX509Entry            = 0
+	sctPrecertEntry         = 1
+	sctHashSha256           = 4
+	sctSignatureRsa         = 1
+	sctSignatureEcdsa       = 3
+
+	// certificates valid for longer than this need a third embedded SCT
+	ctShortLivedDays = 180
+)
+
+var (
+	oidEmbeddedScts = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidOcspScts     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+
+	bundledCtLogs     map[[sha256.Size]byte]*ctLog
+	bundledCtLogsErr  error
+	bundledCtLogsOnce sync.Once
+)
+
+// bundledCtLogList is Chrome's log list, refresh it with go generate
+//
+//go:embed ct_logs.json
+var bundledCtLogList []byte
+
+// CertificateTransparency is every SCT found for the leaf certificate and
+// whether they satisfy the browser CT policies.
+type CertificateTransparency struct {
+	Scts         []Sct
+	Operators    map[string]int
+	ChromePolicy bool
+	ApplePolicy  bool
+}
+
+// Sct is one signed certificate timestamp and how it was delivered.
+type Sct struct {
+	Source    string
+	LogId     string
+	Log       string `json:",omitempty"`
+	Operator  string `json:",omitempty"`
+	Timestamp time.Time
+	Valid     bool
+	Err       string `json:",omitempty"`
+
+	logState      string
+	logStateSince time.Time
+}
+
+type ctLog struct {
+	description string
+	operator    string
+	key         crypto.PublicKey
+	state       string
+	stateSince  time.Time
+}
+
+// the parts of https://www.gstatic.com/ct/log_list/v3/log_list_schema.json that are used
+type ctLogListJson struct {
+	Operators []struct {
+		Name      string           `json:"name"`
+		Logs      []ctLogEntryJson `json:"logs"`
+		TiledLogs []ctLogEntryJson `json:"tiled_logs"`
+	} `json:"operators"`
+}
+
+type ctLogEntryJson struct {
+	Description string `json:"description"`
+	Key         []byte `json:"key"`
+	State       map[string]struct {
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"state"`
+}
+
+type signedCertificateTimestamp struct {
+	version            uint8
+	logId              [sha256.Size]byte
+	timestamp          uint64
+	extensions         []byte
+	hashAlgorithm      uint8
+	signatureAlgorithm uint8
+	signature          []byte
+}
+
+// SetCt turns on Certificate Transparency checks of the leaf certificate.
+func (a *CheckSSL) SetCt(enable bool) {
+	a.ct = enable
+}
+
+// SetCtLogList replaces the bundled CT log list with a file in the same
+// format as https://www.gstatic.com/ct/log_list/v3/log_list.json
+func (a *CheckSSL) SetCtLogList(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	logs, err := parseCtLogList(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	a.ctLogs = logs
+	return nil
+}
+
+func (a *CheckSSL) knownCtLogs() (map[[sha256.Size]byte]*ctLog, error) {
+	if a.ctLogs != nil {
+		return a.ctLogs, nil
+	}
+	bundledCtLogsOnce.Do(func() {
+		bundledCtLogs, bundledCtLogsErr = parseCtLogList(bundledCtLogList)
+		if bundledCtLogsErr != nil {
+			bundledCtLogsErr = fmt.Errorf("bundled ct_logs.json: %w, refresh it with go generate", bundledCtLogsErr)
+		}
+	})
+	return bundledCtLogs, bundledCtLogsErr
+}
+
+func parseCtLogList(data []byte) (map[[sha256.Size]byte]*ctLog, error) {
+	var list ctLogListJson
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CT log list: %w", err)
+	}
+
+	output := map[[sha256.Size]byte]*ctLog{}
+	for _, operator := range list.Operators {
+		for _, entry := range append(operator.Logs, operator.TiledLogs...) {
+			key, err := x509.ParsePKIXPublicKey(entry.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key for CT log %q: %w", entry.Description, err)
+			}
+			log := &ctLog{description: entry.Description, operator: operator.Name, key: key}
+			for state, since := range entry.State {
+				log.state, log.stateSince = state, since.Timestamp
+			}
+			// a log id is the hash of its key
+			output[sha256.Sum256(entry.Key)] = log
+		}
+	}
+	if len(output) == 0 {
+		// every SCT would be from an unknown log
+		return nil, errors.New("CT log list has no logs")
+	}
+	return output, nil
+}
+
+// checkCt collects the leaf certificate's SCTs from the certificate itself,
+// the TLS handshake and the stapled OCSP response, and fails the check when
+// they do not meet the Chrome and Apple CT policies.
+func (a *CheckSSL) checkCt(output *CheckedServer, state *tls.ConnectionState) {
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	leaf := state.PeerCertificates[0]
+	issuer := leafIssuer(state)
+
+	logs, err := a.knownCtLogs()
+	if err != nil {
+		if output.Err == "" {
+			output.Err = err.Error()
+		}
+		if output.ExitCode == RETURNCODE_PASS {
+			output.ExitCode = RETURNCODE_ERROR
+		}
+		output.Passed = false
+		return
+	}
+
+	ct := &CertificateTransparency{Operators: map[string]int{}}
+	addScts := func(source string, list [][]byte) {
+		for _, raw := range list {
+			ct.Scts = append(ct.Scts, verifySct(raw, source, leaf, issuer, logs))
+		}
+	}
+	addScts(SCT_EMBEDDED, sctsFromExtensions(leaf.Extensions, oidEmbeddedScts))
+	addScts(SCT_TLS, state.SignedCertificateTimestamps)
+	if len(state.OCSPResponse) > 0 && issuer != nil {
+		single, err := findOcspResponse(state.OCSPResponse, leaf, issuer)
+		if err == nil {
+			addScts(SCT_OCSP, sctsFromExtensions(single.SingleExtensions, oidOcspScts))
+		}
+	}
+
+	for _, sct := range ct.Scts {
+		if sct.Valid {
+			ct.Operators[sct.Operator]++
+		}
+	}
+	ct.ChromePolicy, ct.ApplePolicy = ctPolicies(ct.Scts, leaf)
+	output.Ct = ct
+
+	var failed []string
+	if !ct.ChromePolicy {
+		failed = append(failed, "Chrome")
+	}
+	if !ct.ApplePolicy {
+		failed = append(failed, "Apple")
+	}
+	if len(failed) > 0 {
+		if output.Err == "" {
+			output.Err = "certificate does not meet the " + strings.Join(failed, " and ") + " CT policy"
+		}
+		if output.ExitCode == RETURNCODE_PASS {
+			output.ExitCode = RETURNCODE_ERROR
+		}
+		output.Passed = false
+	}
+}
+
+// sctsFromExtensions finds the SCT list extension, an OCTET STRING holding a
+// TLS encoded list of SCTs.
+func sctsFromExtensions(extensions []pkix.Extension, oid asn1.ObjectIdentifier) [][]byte {
+	for _, extension := range extensions {
+		if !extension.Id.Equal(oid) {
+			continue
+		}
+		var list []byte
+		_, err := asn1.Unmarshal(extension.Value, &list)
+		if err != nil {
+			return nil
+		}
+		scts, err := parseSctList(list)
+		if err != nil {
+			return nil
+		}
+		return scts
+	}
+	return nil
+}
+
+func parseSctList(data []byte) ([][]byte, error) {
+	list, rest, err := readTlsVector(data, 2)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("invalid SCT list")
+	}
+	var output [][]byte
+	for len(list) > 0 {
+		var sct []byte
+		sct, list, err = readTlsVector(list, 2)
+		if err != nil {
+			return nil, errors.New("invalid SCT list")
+		}
+		output = append(output, sct)
+	}
+	return output, nil
+}
+
+// readTlsVector reads a TLS vector with a lengthBytes long big endian length.
+func readTlsVector(data []byte, lengthBytes int) (vector []byte, rest []byte, err error) {
+	if len(data) < lengthBytes {
+		return nil, nil, errors.New("truncated")
+	}
+	length := 0
+	for _, b := range data[:lengthBytes] {
+		length = length<<8 | int(b)
+	}
+	data = data[lengthBytes:]
+	if len(data) < length {
+		return nil, nil, errors.New("truncated")
+	}
+	return data[:length], data[length:], nil
+}
+
+func parseSct(data []byte) (sct signedCertificateTimestamp, err error) {
+	const fixedLength = 1 + sha256.Size + 8
+	if len(data) < fixedLength {
+		return sct, errors.New("SCT is truncated")
+	}
+	sct.version = data[0]
+	if sct.version != sctVersion1 {
+		return sct, fmt.Errorf("unsupported SCT version %d", sct.version)
+	}
+	copy(sct.logId[:], data[1:])
+	sct.timestamp = binary.BigEndian.Uint64(data[1+sha256.Size:])
+
+	sct.extensions, data, err = readTlsVector(data[fixedLength:], 2)
+	if err != nil || len(data) < 2 {
+		return sct, errors.New("SCT is truncated")
+	}
+	sct.hashAlgorithm, sct.signatureAlgorithm = data[0], data[1]
+	sct.signature, data, err = readTlsVector(data[2:], 2)
+	if err != nil || len(data) > 0 {
+		return sct, errors.New("SCT is truncated")
+	}
+	return sct, nil
+}
+
+// sctSignedData is what the log signed, from RFC 6962 section 3.2
+func sctSignedData(sct signedCertificateTimestamp, entryType uint16, entry []byte) []byte {
+	data := []byte{sct.version, sctCertificateTimestamp}
+	data = binary.BigEndian.AppendUint64(data, sct.timestamp)
+	data = binary.BigEndian.AppendUint16(data, entryType)
+	data = append(data, entry...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(sct.extensions)))
+	return append(data, sct.extensions...)
+}
+
+// sctEntry is the log entry an SCT covers. SCTs embedded in the certificate
+// were signed over the precertificate, which is the certificate without the
+// SCT extension plus a hash of the issuer key.
+func sctEntry(source string, leaf *x509.Certificate, issuer *x509.Certificate) (uint16, []byte, error) {
+	if source != SCT_EMBEDDED {
+		return sctX509Entry, appendUint24Vector(nil, leaf.Raw), nil
+	}
+	if issuer == nil {
+		return 0, nil, errors.New("issuer certificate was not presented")
+	}
+	tbs, err := removeExtension(leaf.RawTBSCertificate, oidEmbeddedScts)
+	if err != nil {
+		return 0, nil, err
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	return sctPrecertEntry, appendUint24Vector(issuerKeyHash[:], tbs), nil
+}
+
+func appendUint24Vector(data []byte, vector []byte) []byte {
+	data = append(data, byte(len(vector)>>16), byte(len(vector)>>8), byte(len(vector)))
+	return append(data, vector...)
+}
+
+func verifySct(raw []byte, source string, leaf *x509.Certificate, issuer *x509.Certificate, logs map[[sha256.Size]byte]*ctLog) (output Sct) {
+	output.Source = source
+	sct, err := parseSct(raw)
+	if err != nil {
+		output.Err = err.Error()
+		return
+	}
+	output.LogId = base64.StdEncoding.EncodeToString(sct.logId[:])
+	output.Timestamp = time.UnixMilli(int64(sct.timestamp)).UTC()
+
+	log, found := logs[sct.logId]
+	if !found {
+		output.Err = "SCT is from an unknown log"
+		return
+	}
+	output.Log, output.Operator = log.description, log.operator
+	output.logState, output.logStateSince = log.state, log.stateSince
+
+	entryType, entry, err := sctEntry(source, leaf, issuer)
+	if err != nil {
+		output.Err = err.Error()
+		return
+	}
+	err = log.verify(sct, sctSignedData(sct, entryType, entry))
+	if err != nil {
+		output.Err = err.Error()
+		return
+	}
+	if output.Timestamp.After(time.Now()) {
+		output.Err = "SCT timestamp is in the future"
+		return
+	}
+	output.Valid = true
+	return
+}
+
+func (a *ctLog) verify(sct signedCertificateTimestamp, signed []byte) error {
+	if sct.hashAlgorithm != sctHashSha256 {
+		return fmt.Errorf("unsupported SCT hash algorithm %d", sct.hashAlgorithm)
+	}
+	digest := sha256.Sum256(signed)
+
+	valid := false
+	switch key := a.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = sct.signatureAlgorithm == sctSignatureEcdsa && ecdsa.VerifyASN1(key, digest[:], sct.signature)
+	case *rsa.PublicKey:
+		valid = sct.signatureAlgorithm == sctSignatureRsa && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sct.signature) == nil
+	}
+	if !valid {
+		return errors.New("SCT signature is invalid")
+	}
+	return nil
+}
+
+// removeExtension returns tbs, a DER TBSCertificate, without the extension
+// oid. Everything else is copied byte for byte so signatures over it still
+// verify.
+func removeExtension(tbs []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	var certificate asn1.RawValue
+	_, err := asn1.Unmarshal(tbs, &certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []byte
+	for rest := certificate.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		rest, err = asn1.Unmarshal(rest, &field)
+		if err != nil {
+			return nil, err
+		}
+		// extensions are the explicitly tagged [3] field
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 3 {
+			field.FullBytes, err = removeFromExtensions(field, oid)
+			if err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field.FullBytes...)
+	}
+	return asn1.Marshal(asn1.RawValue{Class: certificate.Class, Tag: certificate.Tag, IsCompound: true, Bytes: fields})
+}
+
+func removeFromExtensions(field asn1.RawValue, oid asn1.ObjectIdentifier) ([]byte, error) {
+	var sequence asn1.RawValue
+	_, err := asn1.Unmarshal(field.Bytes, &sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []byte
+	for rest := sequence.Bytes; len(rest) > 0; {
+		var extension asn1.RawValue
+		rest, err = asn1.Unmarshal(rest, &extension)
+		if err != nil {
+			return nil, err
+		}
+		var parsed pkix.Extension
+		_, err = asn1.Unmarshal(extension.FullBytes, &parsed)
+		if err != nil {
+			return nil, err
+		}
+		if !parsed.Id.Equal(oid) {
+			kept = append(kept, extension.FullBytes...)
+		}
+	}
+
+	sequenceBytes, err := asn1.Marshal(asn1.RawValue{Class: sequence.Class, Tag: sequence.Tag, IsCompound: true, Bytes: kept})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(asn1.RawValue{Class: field.Class, Tag: field.Tag, IsCompound: true, Bytes: sequenceBytes})
+}
+
+// ctPolicies is a simplified version of the Chrome and Apple CT policies.
+// SCTs embedded in the certificate need to come from two distinct logs, or
+// three when the certificate is valid for more than 180 days. SCTs sent in
+// the handshake or OCSP response need two logs that are usable today. Either
+// way the SCTs need to come from at least two log operators.
+func ctPolicies(scts []Sct, leaf *x509.Certificate) (chrome bool, apple bool) {
+	required := 2
+	if leaf.NotAfter.Sub(leaf.NotBefore) > ctShortLivedDays*24*time.Hour {
+		required = 3
+	}
+
+	delivered, deliveredOperators := countCtLogs(scts, func(sct Sct) bool {
+		return sct.Source != SCT_EMBEDDED && sct.isCurrentLog()
+	})
+	deliveredPolicy := delivered >= 2 && deliveredOperators >= 2
+
+	// Chrome accepts SCTs from retired logs if they were made before the log retired
+	embedded, embeddedOperators := countCtLogs(scts, func(sct Sct) bool {
+		return sct.Source == SCT_EMBEDDED && (sct.isCurrentLog() || (sct.logState == ctLogRetired && sct.Timestamp.Before(sct.logStateSince)))
+	})
+	chrome = deliveredPolicy || (embedded >= required && embeddedOperators >= 2)
+
+	// Apple accepts any log it once approved, as long as one is still approved
+	embedded, embeddedOperators = countCtLogs(scts, func(sct Sct) bool {
+		return sct.Source == SCT_EMBEDDED && (sct.isCurrentLog() || sct.logState == ctLogRetired)
+	})
+	current, _ := countCtLogs(scts, func(sct Sct) bool {
+		return sct.Source == SCT_EMBEDDED && sct.isCurrentLog()
+	})
+	apple = deliveredPolicy || (embedded >= required && embeddedOperators >= 2 && current >= 1)
+	return
+}
+
+func (a Sct) isCurrentLog() bool {
+	return a.logState == ctLogUsable || a.logState == ctLogQualified || a.logState == ctLogReadOnly
+}
+
+// countCtLogs counts the distinct logs and operators of the valid SCTs that match.
+func countCtLogs(scts []Sct, match func(Sct) bool) (logs int, operators int) {
+	seenLogs := map[string]bool{}
+	seenOperators := map[string]bool{}
+	for _, sct := range scts {
+		if sct.Valid && match(sct) {
+			seenLogs[sct.LogId] = true
+			seenOperators[sct.Operator] = true
+		}
+	}
+	return len(seenLogs), len(seenOperators)
+}
+
+// ctLine is the Certificate Transparency summary in text output.
+func (a CheckedServer) ctLine(color terminalColors) string {
+	if a.Ct == nil {
+		return ""
+	}
+
+	var operators []string
+	valid := 0
+	for operator, count := range a.Ct.Operators {
+		operators = append(operators, fmt.Sprintf("%s %d", operator, count))
+		valid += count
+	}
+	sort.Strings(operators)
+
+	output := fmt.Sprintf(" -> %d valid SCTs", valid)
+	if len(operators) > 0 {
+		output += " (" + strings.Join(operators, ", ") + ")"
+	}
+	if invalid := len(a.Ct.Scts) - valid; invalid > 0 {
+		output += fmt.Sprintf(", %s%d invalid%s", color.yellow, invalid, color.noColor)
+	}
+	return output + fmt.Sprintf(", Chrome CT policy %s, Apple CT policy %s\n", ctPolicyMet(a.Ct.ChromePolicy, color), ctPolicyMet(a.Ct.ApplePolicy, color))
+}
+
+func ctPolicyMet(met bool, color terminalColors) string {
+	if met {
+		return color.green + "met" + color.noColor
+	}
+	return color.red + "not met" + color.noColor
+}
@@ -0,0 +1,329 @@
+package checkssl
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCtLog struct {
+	operator string
+	key      *ecdsa.PrivateKey
+	state    string
+}
+
+func newTestCtLog(t *testing.T, operator string) testCtLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCtLog{operator: operator, key: key, state: ctLogUsable}
+}
+
+func (l testCtLog) id(t *testing.T) [sha256.Size]byte {
+	der, err := x509.MarshalPKIXPublicKey(l.key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha256.Sum256(der)
+}
+
+// writeCtLogList writes logs to a file in the same format as Chrome's log list.
+func writeCtLogList(t *testing.T, logs ...testCtLog) string {
+	type entry struct {
+		Description string                       `json:"description"`
+		Key         []byte                       `json:"key"`
+		State       map[string]map[string]string `json:"state"`
+	}
+	operators := map[string][]entry{}
+	for i, log := range logs {
+		der, err := x509.MarshalPKIXPublicKey(log.key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		operators[log.operator] = append(operators[log.operator], entry{
+			Description: log.operator + " log " + string(rune('A'+i)),
+			Key:         der,
+			State:       map[string]map[string]string{log.state: {"timestamp": "2020-01-01T00:00:00Z"}},
+		})
+	}
+	var list struct {
+		Operators []map[string]interface{} `json:"operators"`
+	}
+	for name, entries := range operators {
+		list.Operators = append(list.Operators, map[string]interface{}{"name": name, "logs": entries})
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "log_list.json")
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestSct has log sign entry the same way a real CT log would.
+func newTestSct(t *testing.T, log testCtLog, entryType uint16, entry []byte) []byte {
+	sct := signedCertificateTimestamp{
+		logId:     log.id(t),
+		timestamp: uint64(time.Now().Add(-time.Hour).UnixMilli()),
+	}
+	digest := sha256.Sum256(sctSignedData(sct, entryType, entry))
+	signature, err := ecdsa.SignASN1(rand.Reader, log.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := append([]byte{sctVersion1}, sct.logId[:]...)
+	output = binary.BigEndian.AppendUint64(output, sct.timestamp)
+	output = append(output, 0, 0, sctHashSha256, sctSignatureEcdsa)
+	output = binary.BigEndian.AppendUint16(output, uint16(len(signature)))
+	return append(output, signature...)
+}
+
+func newTestSctListExtension(t *testing.T, oid asn1.ObjectIdentifier, scts ...[]byte) pkix.Extension {
+	var list []byte
+	for _, sct := range scts {
+		list = binary.BigEndian.AppendUint16(list, uint16(len(sct)))
+		list = append(list, sct...)
+	}
+	value, err := asn1.Marshal(append(binary.BigEndian.AppendUint16(nil, uint16(len(list))), list...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oid, Value: value}
+}
+
+// newTestLeafWithEmbeddedScts issues a precertificate, has each log sign it,
+// and then issues the final certificate with the SCTs embedded.
+func newTestLeafWithEmbeddedScts(t *testing.T, ca *testCertificate, lifetime time.Duration, logs ...testCtLog) (*testCertificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		NotBefore:    time.Now().Add(-time.Hour).Truncate(time.Second),
+		NotAfter:     time.Now().Add(lifetime).Truncate(time.Second),
+	}
+	create := func() *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	precert := create()
+	issuerKeyHash := sha256.Sum256(ca.Cert.RawSubjectPublicKeyInfo)
+	entry := appendUint24Vector(issuerKeyHash[:], precert.RawTBSCertificate)
+	var scts [][]byte
+	for _, log := range logs {
+		scts = append(scts, newTestSct(t, log, sctPrecertEntry, entry))
+	}
+	template.ExtraExtensions = []pkix.Extension{newTestSctListExtension(t, oidEmbeddedScts, scts...)}
+	return &testCertificate{Cert: create(), Key: key}, precert
+}
+
+func testCt(t *testing.T, certificate tls.Certificate, logs ...testCtLog) CheckedServer {
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{certificate}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetCt(true)
+	err := checker.SetCtLogList(writeCtLogList(t, logs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checker.CheckServer("tls://"+address, true)
+}
+
+func Test_removeExtension(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf, precert := newTestLeafWithEmbeddedScts(t, ca, 24*time.Hour, newTestCtLog(t, "Google"))
+
+	actual, err := removeExtension(leaf.Cert.RawTBSCertificate, oidEmbeddedScts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, precert.RawTBSCertificate) {
+		t.Error("expected removing the SCT extension to give back the precertificate")
+	}
+}
+
+func Test_CheckServer_ctEmbedded(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	google, cloudflare := newTestCtLog(t, "Google"), newTestCtLog(t, "Cloudflare")
+	leaf, _ := newTestLeafWithEmbeddedScts(t, ca, 90*24*time.Hour, google, cloudflare)
+	actual := testCt(t, leaf.tlsCertificate(ca), google, cloudflare)
+
+	if !actual.Passed || !actual.Ct.ChromePolicy || !actual.Ct.ApplePolicy {
+		t.Fatal("expected two embedded SCTs from two operators to pass", actual.Err, actual.Ct)
+	}
+	if actual.Ct.Operators["Google"] != 1 || actual.Ct.Operators["Cloudflare"] != 1 || actual.Ct.Scts[0].Source != SCT_EMBEDDED {
+		t.Error("expected one embedded SCT per operator", actual.Ct)
+	}
+	if !strings.Contains(actual.AsString(false), " -> 2 valid SCTs (Cloudflare 1, Google 1), Chrome CT policy met, Apple CT policy met") {
+		t.Error("expected the CT summary in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_ctEmbeddedLongLived(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	google, cloudflare := newTestCtLog(t, "Google"), newTestCtLog(t, "Cloudflare")
+	leaf, _ := newTestLeafWithEmbeddedScts(t, ca, 300*24*time.Hour, google, cloudflare)
+	actual := testCt(t, leaf.tlsCertificate(ca), google, cloudflare)
+
+	if actual.Passed || actual.Ct.ChromePolicy || actual.Ct.ApplePolicy {
+		t.Error("expected a certificate valid for more than 180 days to need three SCTs")
+	}
+	assert(t, actual.Err, "certificate does not meet the Chrome and Apple CT policy", "")
+}
+
+func Test_CheckServer_ctTlsExtension(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	google, cloudflare := newTestCtLog(t, "Google"), newTestCtLog(t, "Cloudflare")
+	certificate := leaf.tlsCertificate(ca)
+	entry := appendUint24Vector(nil, leaf.Cert.Raw)
+	certificate.SignedCertificateTimestamps = [][]byte{newTestSct(t, google, sctX509Entry, entry), newTestSct(t, cloudflare, sctX509Entry, entry)}
+	actual := testCt(t, certificate, google, cloudflare)
+
+	if !actual.Passed || len(actual.Ct.Scts) != 2 || actual.Ct.Scts[0].Source != SCT_TLS {
+		t.Error("expected SCTs from the TLS extension to pass", actual.Err, actual.Ct)
+	}
+}
+
+func Test_CheckServer_ctOcspStaple(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	google, cloudflare := newTestCtLog(t, "Google"), newTestCtLog(t, "Cloudflare")
+	entry := appendUint24Vector(nil, leaf.Cert.Raw)
+	certificate := leaf.tlsCertificate(ca)
+	certificate.OCSPStaple = newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{
+		Good:             true,
+		SingleExtensions: []pkix.Extension{newTestSctListExtension(t, oidOcspScts, newTestSct(t, google, sctX509Entry, entry), newTestSct(t, cloudflare, sctX509Entry, entry))},
+	})
+	actual := testCt(t, certificate, google, cloudflare)
+
+	if !actual.Passed || len(actual.Ct.Scts) != 2 || actual.Ct.Scts[0].Source != SCT_OCSP {
+		t.Error("expected SCTs from the stapled OCSP response to pass", actual.Err, actual.Ct)
+	}
+}
+
+func Test_CheckServer_ctOneOperator(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	first, second := newTestCtLog(t, "Google"), newTestCtLog(t, "Google")
+	leaf, _ := newTestLeafWithEmbeddedScts(t, ca, 90*24*time.Hour, first, second)
+	actual := testCt(t, leaf.tlsCertificate(ca), first, second)
+
+	if actual.Passed || actual.Ct.ChromePolicy || actual.Ct.Operators["Google"] != 2 {
+		t.Error("expected SCTs from a single operator to fail", actual.Ct)
+	}
+}
+
+func Test_CheckServer_ctKeepsExitCode(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		NotBefore:   time.Now().Add(-48 * time.Hour),
+		NotAfter:    time.Now().Add(-24 * time.Hour),
+	}, ca)
+	actual := testCt(t, leaf.tlsCertificate(ca), newTestCtLog(t, "Google"))
+
+	if actual.Passed || actual.Ct.ChromePolicy {
+		t.Error("expected a certificate without SCTs to fail the CT policy", actual.Ct)
+	}
+	if actual.ExitCode != RETURNCODE_EXPIRED {
+		t.Error("expected an expired certificate to keep its return code", actual.ExitCode)
+	}
+}
+
+func Test_CheckServer_ctUnknownLog(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	google, cloudflare, unknown := newTestCtLog(t, "Google"), newTestCtLog(t, "Cloudflare"), newTestCtLog(t, "Unknown")
+	leaf, _ := newTestLeafWithEmbeddedScts(t, ca, 90*24*time.Hour, google, unknown)
+	actual := testCt(t, leaf.tlsCertificate(ca), google, cloudflare)
+
+	if actual.Passed || actual.Ct.Scts[1].Valid || actual.Ct.Scts[1].Err != "SCT is from an unknown log" {
+		t.Error("expected an SCT from a log that is not in the list to be invalid", actual.Ct)
+	}
+	if !strings.Contains(actual.AsString(false), "1 valid SCTs (Google 1), 1 invalid") {
+		t.Error("expected the invalid SCT in text output", actual.AsString(false))
+	}
+}
+
+func Test_ctPolicies_retiredLogs(t *testing.T) {
+	leaf := &x509.Certificate{NotBefore: time.Now(), NotAfter: time.Now().Add(90 * 24 * time.Hour)}
+	retiredAt := time.Now().Add(-time.Hour)
+	scts := []Sct{
+		{Source: SCT_EMBEDDED, LogId: "a", Operator: "Google", Valid: true, Timestamp: retiredAt.Add(-time.Hour), logState: ctLogRetired, logStateSince: retiredAt},
+		{Source: SCT_EMBEDDED, LogId: "b", Operator: "Cloudflare", Valid: true, Timestamp: retiredAt.Add(-time.Hour), logState: ctLogUsable},
+	}
+	chrome, apple := ctPolicies(scts, leaf)
+	if !chrome || !apple {
+		t.Error("an SCT from before the log retired should still count", chrome, apple)
+	}
+
+	scts[0].Timestamp = retiredAt.Add(time.Minute)
+	chrome, _ = ctPolicies(scts, leaf)
+	if chrome {
+		t.Error("Chrome should not count an SCT made after the log retired")
+	}
+}
+
+func Test_bundledCtLogList(t *testing.T) {
+	logs, err := parseCtLogList(bundledCtLogList)
+	if err != nil {
+		t.Fatal("the bundled CT log list should parse, run go generate ./lib/checkssl", err)
+	}
+	operators := map[string]bool{}
+	for _, log := range logs {
+		operators[log.operator] = true
+	}
+	if len(operators) < 2 {
+		t.Error("expected logs from at least two operators, got", operators)
+	}
+}
+
+func Test_parseCtLogList_empty(t *testing.T) {
+	_, err := parseCtLogList([]byte(`{"operators": []}`))
+	if err == nil || err.Error() != "CT log list has no logs" {
+		t.Error("expected an empty list to be refused", err)
+	}
+
+	checker := NewCheckSSL()
+	err = checker.SetCtLogList(writeCtLogList(t))
+	if err == nil {
+		t.Error("expected an empty -ct-logs file to be refused")
+	}
+}
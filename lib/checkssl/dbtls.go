@@ -0,0 +1,132 @@
+package checkssl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	postgresSslRequestCode = 80877103
+
+	mysqlClientLongPassword      = 0x00000001
+	mysqlClientProtocol41        = 0x00000200
+	mysqlClientSsl               = 0x00000800
+	mysqlClientSecureConnection  = 0x00008000
+	mysqlMaxPacketSize           = 1 << 24
+	mysqlCharsetUtf8mb4          = 45
+	mysqlProtocolVersion         = 10
+	mysqlErrorPacket             = 0xff
+	mysqlHandshakeCapabilityBits = mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientSsl | mysqlClientSecureConnection
+)
+
+// startTlsPostgres sends the SSLRequest message and expects the server to
+// answer 'S' before it will accept a TLS handshake.
+func startTlsPostgres(conn net.Conn, serverName string) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSslRequestCode)
+	_, err := conn.Write(request)
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return fmt.Errorf("postgres SSLRequest failed: %w", err)
+	}
+	switch reply[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return errors.New("postgres server does not accept SSL connections")
+	}
+	return fmt.Errorf("postgres SSLRequest failed: unexpected reply %q", reply[0])
+}
+
+// startTlsMysql reads the server's initial handshake packet and, if it
+// advertises CLIENT_SSL, replies with an SSLRequest packet.
+func startTlsMysql(conn net.Conn, serverName string) error {
+	sequence, payload, err := readMysqlPacket(conn)
+	if err != nil {
+		return fmt.Errorf("mysql handshake failed: %w", err)
+	}
+	if len(payload) > 0 && payload[0] == mysqlErrorPacket {
+		return fmt.Errorf("mysql handshake failed: %s", mysqlErrorMessage(payload))
+	}
+
+	capabilities, err := mysqlServerCapabilities(payload)
+	if err != nil {
+		return err
+	}
+	if capabilities&mysqlClientSsl == 0 {
+		return errors.New("mysql server does not accept SSL connections")
+	}
+
+	request := make([]byte, 32)
+	binary.LittleEndian.PutUint32(request[0:4], mysqlHandshakeCapabilityBits)
+	binary.LittleEndian.PutUint32(request[4:8], mysqlMaxPacketSize)
+	request[8] = mysqlCharsetUtf8mb4
+	return writeMysqlPacket(conn, sequence+1, request)
+}
+
+// mysqlServerCapabilities pulls the capability flags out of a v10 initial
+// handshake packet, which are split in two halves around the charset and status.
+func mysqlServerCapabilities(payload []byte) (uint32, error) {
+	if len(payload) == 0 || payload[0] != mysqlProtocolVersion {
+		return 0, errors.New("mysql handshake failed: unsupported protocol version")
+	}
+
+	versionEnd := bytes.IndexByte(payload[1:], 0)
+	if versionEnd < 0 {
+		return 0, errors.New("mysql handshake failed: malformed server version")
+	}
+	// version + null, connection id (4), auth data (8), filler (1)
+	offset := 1 + versionEnd + 1 + 4 + 8 + 1
+	if len(payload) < offset+2 {
+		return 0, errors.New("mysql handshake failed: packet too short")
+	}
+	capabilities := uint32(binary.LittleEndian.Uint16(payload[offset : offset+2]))
+
+	// charset (1), status (2), then the upper capability flags
+	offset += 2 + 1 + 2
+	if len(payload) >= offset+2 {
+		capabilities |= uint32(binary.LittleEndian.Uint16(payload[offset:offset+2])) << 16
+	}
+	return capabilities, nil
+}
+
+func mysqlErrorMessage(payload []byte) string {
+	// 0xff, error code (2), optional '#' + sql state (5), message
+	if len(payload) < 3 {
+		return "unknown error"
+	}
+	message := payload[3:]
+	if len(message) > 6 && message[0] == '#' {
+		message = message[6:]
+	}
+	return string(message)
+}
+
+func readMysqlPacket(conn net.Conn) (byte, []byte, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	_, err = io.ReadFull(conn, payload)
+	return header[3], payload, err
+}
+
+func writeMysqlPacket(conn net.Conn, sequence byte, payload []byte) error {
+	length := len(payload)
+	packet := append([]byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}, payload...)
+	_, err := conn.Write(packet)
+	return err
+}
@@ -0,0 +1,97 @@
+package checkssl
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func fakePostgres(reply byte) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		request := make([]byte, 8)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return false
+		}
+		if binary.BigEndian.Uint32(request[4:]) != postgresSslRequestCode {
+			return false
+		}
+		_, _ = conn.Write([]byte{reply})
+		return reply == 'S'
+	}
+}
+
+func mysqlGreeting(capabilities uint32) []byte {
+	payload := []byte{mysqlProtocolVersion}
+	payload = append(payload, "8.0.36\x00"...)
+	payload = append(payload, 1, 0, 0, 0)        // connection id
+	payload = append(payload, "abcdefgh\x00"...) // auth data + filler
+	payload = append(payload, byte(capabilities), byte(capabilities>>8))
+	payload = append(payload, mysqlCharsetUtf8mb4, 2, 0)
+	payload = append(payload, byte(capabilities>>16), byte(capabilities>>24))
+	return payload
+}
+
+func fakeMysql(capabilities uint32) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		if writeMysqlPacket(conn, 0, mysqlGreeting(capabilities)) != nil {
+			return false
+		}
+		sequence, payload, err := readMysqlPacket(conn)
+		if err != nil || sequence != 1 || len(payload) != 32 {
+			return false
+		}
+		return binary.LittleEndian.Uint32(payload)&mysqlClientSsl != 0
+	}
+}
+
+func Test_CheckServer_postgres(t *testing.T) {
+	address := fakePreambleServer(t, fakePostgres('S'))
+	actual := testStartTls(t, "postgres://"+address)
+	assert(t, actual.Target, "postgres://"+address, "")
+}
+
+func Test_CheckServer_postgresWithoutSsl(t *testing.T) {
+	address := fakePreambleServer(t, fakePostgres('N'))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("postgresql://"+address, false)
+	if actual.Passed || !strings.Contains(actual.Err, "does not accept SSL") {
+		t.Error("expected postgres without ssl to fail, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_mysql(t *testing.T) {
+	address := fakePreambleServer(t, fakeMysql(mysqlClientProtocol41|mysqlClientSsl|mysqlClientSecureConnection))
+	testStartTls(t, "mysql://"+address)
+}
+
+func Test_CheckServer_mysqlWithoutSsl(t *testing.T) {
+	address := fakePreambleServer(t, fakeMysql(mysqlClientProtocol41))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("mysql://"+address, false)
+	if actual.Passed || !strings.Contains(actual.Err, "does not accept SSL") {
+		t.Error("expected mysql without ssl to fail, got", actual.Err)
+	}
+}
+
+func Test_mysqlServerCapabilities(t *testing.T) {
+	actual, err := mysqlServerCapabilities(mysqlGreeting(0x81ff0a0d))
+	if err != nil || actual != 0x81ff0a0d {
+		t.Errorf("expected both halves of the capability flags, got %x %v", actual, err)
+	}
+
+	_, err = mysqlServerCapabilities([]byte{9, 'x'})
+	if err == nil {
+		t.Error("expected an old protocol version to be rejected")
+	}
+}
+
+func Test_mysqlErrorMessage(t *testing.T) {
+	actual := mysqlErrorMessage([]byte("\xff\x6a\x04#HY000Host 'x' is not allowed to connect"))
+	assert(t, actual, "Host 'x' is not allowed to connect", "")
+}
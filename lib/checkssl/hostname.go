@@ -0,0 +1,128 @@
+package checkssl
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HostnameCoverage explains how the names in the leaf certificate compare
+// with the host that was checked.
+type HostnameCoverage struct {
+	Host        string
+	Matched     bool
+	MatchedName string   `json:",omitempty"`
+	Wildcard    bool     `json:",omitempty"`
+	CloseNames  []string `json:",omitempty"`
+	Diagnosis   string
+}
+
+// checkHostname finds the SAN entry that covers host, or explains why none do.
+func checkHostname(host string, leaf *x509.Certificate) *HostnameCoverage {
+	output := &HostnameCoverage{Host: host}
+
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		for _, san := range leaf.IPAddresses {
+			if san.Equal(ip) {
+				output.Matched, output.MatchedName = true, "IP:"+san.String()
+				output.Diagnosis = fmt.Sprintf("%s matched %s", host, output.MatchedName)
+				return output
+			}
+		}
+		for _, san := range leaf.IPAddresses {
+			output.CloseNames = append(output.CloseNames, "IP:"+san.String())
+		}
+		output.Diagnosis = fmt.Sprintf("certificate does not have an IP SAN for %s", host)
+		return output
+	}
+
+	name := normalizeHostname(host)
+	for _, san := range leaf.DNSNames {
+		if normalizeHostname(san) == name {
+			output.Matched, output.MatchedName = true, "DNS:"+san
+			output.Diagnosis = fmt.Sprintf("%s matched %s", host, output.MatchedName)
+			return output
+		}
+	}
+	for _, san := range leaf.DNSNames {
+		if wildcardMatches(normalizeHostname(san), name) {
+			output.Matched, output.MatchedName, output.Wildcard = true, "DNS:"+san, true
+			output.Diagnosis = fmt.Sprintf("%s matched wildcard %s", host, output.MatchedName)
+			return output
+		}
+	}
+
+	for _, san := range leaf.DNSNames {
+		if isCloseHostname(normalizeHostname(san), name) {
+			output.CloseNames = append(output.CloseNames, "DNS:"+san)
+		}
+	}
+	output.Diagnosis = hostnameDiagnosis(name, leaf, output.CloseNames)
+	return output
+}
+
+func hostnameDiagnosis(name string, leaf *x509.Certificate, closeNames []string) string {
+	if len(leaf.DNSNames) == 0 {
+		if normalizeHostname(leaf.Subject.CommonName) == name {
+			return fmt.Sprintf("certificate only has %s in its common name, which clients no longer accept without a SAN", name)
+		}
+		return fmt.Sprintf("certificate has no DNS SANs and does not cover %s", name)
+	}
+
+	for _, san := range leaf.DNSNames {
+		pattern := normalizeHostname(san)
+		if !strings.HasPrefix(pattern, "*.") {
+			continue
+		}
+		parent := pattern[2:]
+		if parent == name {
+			return fmt.Sprintf("certificate covers %s but not %s", san, name)
+		}
+		if strings.HasSuffix(name, "."+parent) {
+			return fmt.Sprintf("certificate covers %s, which only matches one level of subdomain, but not %s", san, name)
+		}
+	}
+	if len(closeNames) > 0 {
+		return fmt.Sprintf("certificate does not cover %s, close names are %s", name, strings.Join(closeNames, ", "))
+	}
+	return fmt.Sprintf("certificate does not cover %s", name)
+}
+
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// wildcardMatches allows the wildcard to replace exactly one whole label on
+// the left, the same as browsers and crypto/x509.
+func wildcardMatches(pattern string, host string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	label := strings.Index(host, ".")
+	return label > 0 && host[label+1:] == pattern[2:]
+}
+
+// isCloseHostname is true when both names share the same last two labels,
+// e.g. www.example.com and api.example.com
+func isCloseHostname(san string, host string) bool {
+	return baseDomain(strings.TrimPrefix(san, "*.")) == baseDomain(host)
+}
+
+func baseDomain(host string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+func (a *HostnameCoverage) asString(color terminalColors) string {
+	if a == nil {
+		return ""
+	}
+	if a.Matched {
+		return fmt.Sprintf(" -> %s\n", a.Diagnosis)
+	}
+	return fmt.Sprintf(" -> %s%s%s\n", color.red, a.Diagnosis, color.noColor)
+}
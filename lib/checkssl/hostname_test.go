@@ -0,0 +1,104 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_checkHostname_exact(t *testing.T) {
+	leaf := &x509.Certificate{DNSNames: []string{"www.example.com", "Example.com"}}
+	actual := checkHostname("example.com.", leaf)
+
+	if !actual.Matched || actual.Wildcard {
+		t.Fatal("expected an exact match", actual)
+	}
+	assert(t, actual.MatchedName, "DNS:Example.com", "")
+	assert(t, actual.Diagnosis, "example.com. matched DNS:Example.com", "")
+}
+
+func Test_checkHostname_wildcard(t *testing.T) {
+	leaf := &x509.Certificate{DNSNames: []string{"*.example.com"}}
+	actual := checkHostname("api.example.com", leaf)
+
+	if !actual.Matched || !actual.Wildcard {
+		t.Fatal("expected a wildcard match", actual)
+	}
+	assert(t, actual.Diagnosis, "api.example.com matched wildcard DNS:*.example.com", "")
+}
+
+func Test_checkHostname_wildcardDoesNotCoverApex(t *testing.T) {
+	leaf := &x509.Certificate{DNSNames: []string{"*.example.com"}}
+	actual := checkHostname("example.com", leaf)
+
+	if actual.Matched {
+		t.Fatal("a wildcard should not match the bare domain")
+	}
+	assert(t, actual.Diagnosis, "certificate covers *.example.com but not example.com", "")
+	assert(t, strings.Join(actual.CloseNames, " "), "DNS:*.example.com", "")
+}
+
+func Test_checkHostname_wildcardOnlyOneLevel(t *testing.T) {
+	leaf := &x509.Certificate{DNSNames: []string{"*.example.com"}}
+	actual := checkHostname("a.b.example.com", leaf)
+
+	if actual.Matched {
+		t.Fatal("a wildcard should only match one label")
+	}
+	assert(t, actual.Diagnosis, "certificate covers *.example.com, which only matches one level of subdomain, but not a.b.example.com", "")
+}
+
+func Test_checkHostname_closeNames(t *testing.T) {
+	leaf := &x509.Certificate{DNSNames: []string{"www.example.com", "mail.example.com", "example.org"}}
+	actual := checkHostname("api.example.com", leaf)
+
+	assert(t, strings.Join(actual.CloseNames, " "), "DNS:www.example.com DNS:mail.example.com", "")
+	assert(t, actual.Diagnosis, "certificate does not cover api.example.com, close names are DNS:www.example.com, DNS:mail.example.com", "")
+}
+
+func Test_checkHostname_commonNameOnly(t *testing.T) {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "example.com"}}
+	actual := checkHostname("example.com", leaf)
+
+	if actual.Matched {
+		t.Fatal("the common name should not count as a match")
+	}
+	assert(t, actual.Diagnosis, "certificate only has example.com in its common name, which clients no longer accept without a SAN", "")
+}
+
+func Test_checkHostname_ip(t *testing.T) {
+	leaf := &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}
+
+	if !checkHostname("10.0.0.1", leaf).Matched {
+		t.Error("expected the IP SAN to match")
+	}
+	actual := checkHostname("10.0.0.2", leaf)
+	if actual.Matched {
+		t.Fatal("expected a different ip not to match")
+	}
+	assert(t, actual.Diagnosis, "certificate does not have an IP SAN for 10.0.0.2", "")
+}
+
+func Test_CheckServer_hostnameMismatch(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "*.localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+	_, port, _ := net.SplitHostPort(address)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://localhost:"+port, false)
+
+	if actual.Passed || actual.Hostname == nil || actual.Hostname.Matched {
+		t.Fatal("expected the hostname not to be covered", actual.Hostname)
+	}
+	assert(t, actual.Hostname.Host, "localhost", "")
+	if !strings.Contains(actual.AsString(false), " -> certificate covers *.localhost but not localhost\n") {
+		t.Error("expected the diagnosis in text output", actual.AsString(false))
+	}
+	if !strings.Contains(actual.AsJson(), `"Hostname":{"Host":"localhost","Matched":false,"CloseNames":["DNS:*.localhost"]`) {
+		t.Error("expected the coverage in JSON output", actual.AsJson())
+	}
+}
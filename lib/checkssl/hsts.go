@@ -0,0 +1,335 @@
+package checkssl
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:generate go run hsts_preload_gen.go
+
+const (
+	// the shortest max-age https://hstspreload.org accepts
+	hstsPreloadMinAge = 31536000
+
+	hstsForceHttps = "force-https"
+)
+
+var (
+	bundledHstsPreload     map[string]bool
+	bundledHstsPreloadErr  error
+	bundledHstsPreloadOnce sync.Once
+)
+
+// bundledHstsPreloadList is the force-https part of Chromium's HSTS preload
+// list, written by hsts_preload_gen.go, refresh it with go generate
+//
+//go:embed hsts_preload.json
+var bundledHstsPreloadList []byte
+
+// HstsAudit is the Strict-Transport-Security header the server sent, what
+// plain http:// on port 80 answered and whether the host is preloaded. Host is
+// the host of the final response, after any redirects.
+type HstsAudit struct {
+	Host              string
+	Header            string `json:",omitempty"`
+	MaxAge            int
+	IncludeSubDomains bool
+	Preload           bool
+	HttpStatus        int    `json:",omitempty"`
+	HttpLocation      string `json:",omitempty"`
+	HttpErr           string `json:",omitempty"`
+	HttpsRedirect     bool
+	Preloaded         bool
+	PreloadEntry      string   `json:",omitempty"`
+	PreloadErr        string   `json:",omitempty"`
+	Findings          []string `json:",omitempty"`
+}
+
+type hstsPreloadJson struct {
+	Entries []struct {
+		Name              string `json:"name"`
+		Mode              string `json:"mode"`
+		IncludeSubdomains bool   `json:"include_subdomains"`
+	} `json:"entries"`
+}
+
+// hstsPreloadBundle only keeps the names from the force-https entries, the
+// full Chromium list is too large to build into the binary.
+type hstsPreloadBundle struct {
+	IncludeSubdomains []string `json:"include_subdomains"`
+	HostOnly          []string `json:"host_only"`
+}
+
+// SetHsts reads the Strict-Transport-Security header, checks that http:// on
+// port 80 redirects to https:// and looks the host up in the HSTS preload list.
+func (a *CheckSSL) SetHsts(enable bool) {
+	a.hsts = enable
+}
+
+// SetHstsMinAge fails the check when the Strict-Transport-Security header is
+// missing or its max-age is below seconds. Zero only requires the header.
+func (a *CheckSSL) SetHstsMinAge(seconds int) {
+	a.hstsRequired = true
+	a.hstsMinAge = seconds
+}
+
+// SetHstsPreloadList replaces the bundled HSTS preload list with a file in the
+// same format as Chromium's transport_security_state_static.json
+func (a *CheckSSL) SetHstsPreloadList(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	list, err := parseHstsPreloadList(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	a.hstsPreload = list
+	return nil
+}
+
+func (a *CheckSSL) knownHstsPreload() (map[string]bool, error) {
+	if a.hstsPreload != nil {
+		return a.hstsPreload, nil
+	}
+	bundledHstsPreloadOnce.Do(func() {
+		bundledHstsPreload, bundledHstsPreloadErr = parseHstsPreloadBundle(bundledHstsPreloadList)
+		if bundledHstsPreloadErr != nil {
+			bundledHstsPreloadErr = fmt.Errorf("bundled hsts_preload.json: %w, refresh it with go generate", bundledHstsPreloadErr)
+		}
+	})
+	return bundledHstsPreload, bundledHstsPreloadErr
+}
+
+// parseHstsPreloadList keeps the entries that force https, by name, with
+// whether they include subdomains. Chromium's file has // comment lines,
+// which are not valid JSON.
+func parseHstsPreloadList(data []byte) (map[string]bool, error) {
+	var cleaned bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			continue
+		}
+		cleaned.Write(line)
+		cleaned.WriteByte('\n')
+	}
+
+	var list hstsPreloadJson
+	err := json.Unmarshal(cleaned.Bytes(), &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HSTS preload list: %w", err)
+	}
+
+	output := map[string]bool{}
+	for _, entry := range list.Entries {
+		if entry.Mode == hstsForceHttps {
+			output[strings.ToLower(entry.Name)] = entry.IncludeSubdomains
+		}
+	}
+	return nonEmptyHstsPreload(output)
+}
+
+func parseHstsPreloadBundle(data []byte) (map[string]bool, error) {
+	var bundle hstsPreloadBundle
+	err := json.Unmarshal(data, &bundle)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HSTS preload list: %w", err)
+	}
+
+	output := map[string]bool{}
+	for _, name := range bundle.HostOnly {
+		output[strings.ToLower(name)] = false
+	}
+	for _, name := range bundle.IncludeSubdomains {
+		output[strings.ToLower(name)] = true
+	}
+	return nonEmptyHstsPreload(output)
+}
+
+func nonEmptyHstsPreload(list map[string]bool) (map[string]bool, error) {
+	if len(list) == 0 {
+		// every host would look like it is not preloaded
+		return nil, errors.New("HSTS preload list has no force-https entries")
+	}
+	return list, nil
+}
+
+// preloadEntryFor finds the entry covering host, either the host itself or a
+// parent domain that includes its subdomains.
+func preloadEntryFor(list map[string]bool, host string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for name := host; name != ""; {
+		includeSubdomains, found := list[name]
+		if found && (name == host || includeSubdomains) {
+			return name, true
+		}
+		_, parent, more := strings.Cut(name, ".")
+		if !more {
+			break
+		}
+		name = parent
+	}
+	return "", false
+}
+
+// parseHsts follows RFC 6797, directive names are case insensitive and values
+// may be quoted. A header without a valid max-age is ignored by browsers.
+func parseHsts(header string, audit *HstsAudit) bool {
+	validMaxAge := false
+	for _, directive := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			maxAge, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && maxAge >= 0 {
+				audit.MaxAge = int(min(maxAge, math.MaxInt32))
+				validMaxAge = true
+			}
+		case "includesubdomains":
+			audit.IncludeSubDomains = true
+		case "preload":
+			audit.Preload = true
+		}
+	}
+	return validMaxAge
+}
+
+// checkHsts audits the header of the final https:// response, then makes its
+// own request to http:// on port 80 of the same host without following the
+// redirect, and looks that host up in the preload list.
+func (a *CheckSSL) checkHsts(output *CheckedServer, response *http.Response) {
+	host := response.Request.URL.Hostname()
+	audit := &HstsAudit{Host: host, Header: response.Header.Get("Strict-Transport-Security")}
+	output.Hsts = audit
+
+	validHeader := false
+	switch {
+	case audit.Header == "":
+		audit.Findings = append(audit.Findings, "no Strict-Transport-Security header")
+	case !parseHsts(audit.Header, audit):
+		audit.Findings = append(audit.Findings, "Strict-Transport-Security header has no valid max-age, browsers will ignore it")
+	case audit.MaxAge == 0:
+		validHeader = true
+		audit.Findings = append(audit.Findings, "max-age=0 tells browsers to forget the HSTS policy")
+	default:
+		validHeader = true
+	}
+
+	a.checkHttpRedirect(audit, host)
+	if audit.HttpStatus != 0 && !audit.HttpsRedirect {
+		audit.Findings = append(audit.Findings, "http:// does not redirect to https://")
+	}
+
+	if audit.Preload {
+		if audit.MaxAge < hstsPreloadMinAge {
+			audit.Findings = append(audit.Findings, fmt.Sprintf("preload needs a max-age of at least %d", hstsPreloadMinAge))
+		}
+		if !audit.IncludeSubDomains {
+			audit.Findings = append(audit.Findings, "preload needs includeSubDomains")
+		}
+	}
+
+	list, err := a.knownHstsPreload()
+	if err != nil {
+		audit.PreloadErr = err.Error()
+	} else {
+		audit.PreloadEntry, audit.Preloaded = preloadEntryFor(list, host)
+	}
+
+	if !a.hstsRequired {
+		return
+	}
+	failure := ""
+	if !validHeader {
+		failure = "missing a valid Strict-Transport-Security header"
+	} else if audit.MaxAge < a.hstsMinAge {
+		failure = fmt.Sprintf("HSTS max-age of %d is below the minimum of %d", audit.MaxAge, a.hstsMinAge)
+	}
+	if failure != "" {
+		if output.Err == "" {
+			output.Err = failure
+		}
+		if output.ExitCode == RETURNCODE_PASS {
+			output.ExitCode = RETURNCODE_ERROR
+		}
+		output.Passed = false
+	}
+}
+
+// checkHttpRedirect counts any 3xx with an https:// Location as a redirect. A
+// closed port 80 is not a finding, there is nothing to downgrade to. The
+// connection is not kept alive, nothing else is sent to port 80.
+func (a *CheckSSL) checkHttpRedirect(audit *HstsAudit, host string) {
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: a.dialContext, DisableKeepAlives: true},
+		Timeout:   time.Duration(a.timeoutSeconds) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest("HEAD", "http://"+net.JoinHostPort(host, "80")+"/", nil)
+	if err != nil {
+		audit.HttpErr = err.Error()
+		return
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		audit.HttpErr = err.Error()
+		return
+	}
+	response.Body.Close()
+
+	audit.HttpStatus = response.StatusCode
+	location, err := response.Location()
+	if err == nil {
+		audit.HttpLocation = location.String()
+		audit.HttpsRedirect = response.StatusCode >= 300 && response.StatusCode < 400 && location.Scheme == "https"
+	}
+}
+
+func (a CheckedServer) hstsLines(color terminalColors) (output string) {
+	if a.Hsts == nil {
+		return ""
+	}
+
+	if a.Hsts.Header == "" {
+		output += fmt.Sprintf(" -> %sno HSTS header%s\n", color.red, color.noColor)
+	} else {
+		output += fmt.Sprintf(" -> HSTS %s (%.0f days)\n", a.Hsts.Header, float64(a.Hsts.MaxAge)/(24*60*60))
+	}
+
+	switch {
+	case a.Hsts.HttpErr != "":
+		output += fmt.Sprintf(" -> http://%s not reachable: %s\n", a.Hsts.Host, a.Hsts.HttpErr)
+	case a.Hsts.HttpsRedirect:
+		output += fmt.Sprintf(" -> http://%s %d redirects to %s\n", a.Hsts.Host, a.Hsts.HttpStatus, a.Hsts.HttpLocation)
+	case a.Hsts.HttpLocation != "":
+		output += fmt.Sprintf(" -> %shttp://%s %d redirects to %s%s\n", color.red, a.Hsts.Host, a.Hsts.HttpStatus, a.Hsts.HttpLocation, color.noColor)
+	default:
+		output += fmt.Sprintf(" -> %shttp://%s %d without a redirect%s\n", color.red, a.Hsts.Host, a.Hsts.HttpStatus, color.noColor)
+	}
+
+	if a.Hsts.PreloadErr != "" {
+		output += fmt.Sprintf(" -> %sHSTS preload list not available: %s%s\n", color.yellow, a.Hsts.PreloadErr, color.noColor)
+	} else if a.Hsts.Preloaded {
+		output += fmt.Sprintf(" -> %s%s is on the HSTS preload list%s as %s\n", color.green, a.Hsts.Host, color.noColor, a.Hsts.PreloadEntry)
+	} else {
+		output += fmt.Sprintf(" -> %s is not on the HSTS preload list\n", a.Hsts.Host)
+	}
+	for _, finding := range a.Hsts.Findings {
+		output += fmt.Sprintf("      %s%s%s\n", color.yellow, finding, color.noColor)
+	}
+	return
+}
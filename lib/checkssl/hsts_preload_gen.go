@@ -0,0 +1,93 @@
+//go:build ignore
+
+// hsts_preload_gen.go downloads Chromium's HSTS preload list and writes the
+// names of its force-https entries to hsts_preload.json, split by whether they
+// include subdomains. Pins, policies and the other fields are left out.
+//
+//	go run hsts_preload_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const source = "https://raw.githubusercontent.com/chromium/chromium/main/net/http/transport_security_state_static.json"
+
+func main() {
+	err := generate("hsts_preload.json")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "hsts_preload_gen:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(path string) error {
+	client := &http.Client{Timeout: 60 * time.Second}
+	response, err := client.Get(source)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", source, response.Status)
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	// Chromium's file has // comment lines, which are not valid JSON
+	var cleaned bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			cleaned.Write(line)
+			cleaned.WriteByte('\n')
+		}
+	}
+	var list struct {
+		Entries []struct {
+			Name              string `json:"name"`
+			Mode              string `json:"mode"`
+			IncludeSubdomains bool   `json:"include_subdomains"`
+		} `json:"entries"`
+	}
+	err = json.Unmarshal(cleaned.Bytes(), &list)
+	if err != nil {
+		return err
+	}
+
+	bundle := struct {
+		IncludeSubdomains []string `json:"include_subdomains"`
+		HostOnly          []string `json:"host_only"`
+	}{IncludeSubdomains: []string{}, HostOnly: []string{}}
+	for _, entry := range list.Entries {
+		if entry.Mode != "force-https" {
+			continue
+		}
+		name := strings.ToLower(entry.Name)
+		if entry.IncludeSubdomains {
+			bundle.IncludeSubdomains = append(bundle.IncludeSubdomains, name)
+		} else {
+			bundle.HostOnly = append(bundle.HostOnly, name)
+		}
+	}
+	if len(bundle.IncludeSubdomains)+len(bundle.HostOnly) == 0 {
+		return fmt.Errorf("%s has no force-https entries", source)
+	}
+	sort.Strings(bundle.IncludeSubdomains)
+	sort.Strings(bundle.HostOnly)
+
+	output, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(output, '\n'), 0644)
+}
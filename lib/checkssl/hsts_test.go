@@ -0,0 +1,265 @@
+package checkssl
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHstsPreloadList = `{
+  // comments like Chromium's
+  "entries": [
+    { "name": "example.com", "policy": "custom", "mode": "force-https", "include_subdomains": true },
+    // only pins, no https
+    { "name": "pinned.example.net", "policy": "custom", "include_subdomains": true },
+    { "name": "example.org", "policy": "custom", "mode": "force-https" }
+  ]
+}`
+
+func hstsHeader(value string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+	})
+}
+
+// startHttpTestServer answers plain http:// for port 80 of 127.0.0.1.
+func startHttpTestServer(t *testing.T, checker *CheckSSL, handler http.Handler) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	checker.SetConnectTo("127.0.0.1:80", server.Listener.Addr().String())
+}
+
+func Test_parseHsts(t *testing.T) {
+	actual := HstsAudit{}
+	valid := parseHsts(`Max-Age="31536000"; includeSubDomains ;PRELOAD`, &actual)
+	if !valid || actual.MaxAge != 31536000 || !actual.IncludeSubDomains || !actual.Preload {
+		t.Error("expected every directive to be read", actual)
+	}
+
+	for _, header := range []string{"includeSubDomains", "max-age=-1", "max-age=forever"} {
+		if parseHsts(header, &HstsAudit{}) {
+			t.Error("expected no valid max-age in", header)
+		}
+	}
+}
+
+func Test_preloadEntryFor(t *testing.T) {
+	list, err := parseHstsPreloadList([]byte(testHstsPreloadList))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, expected := range map[string]string{
+		"example.com":            "example.com",
+		"WWW.example.com.":       "example.com",
+		"example.org":            "example.org",
+		"www.example.org":        "",
+		"pinned.example.net":     "",
+		"notexample.com":         "",
+		"www.pinned.example.net": "",
+	} {
+		entry, preloaded := preloadEntryFor(list, host)
+		if entry != expected || preloaded != (expected != "") {
+			t.Error("unexpected preload entry for", host, entry, preloaded)
+		}
+	}
+}
+
+func Test_SetHstsPreloadList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "preload.json")
+	err := os.WriteFile(path, []byte(`{"entries": [`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewCheckSSL()
+	err = checker.SetHstsPreloadList(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": invalid HSTS preload list") {
+		t.Error("expected the broken list to be named", err)
+	}
+
+	err = os.WriteFile(path, []byte(`{"entries": [{"name": "pinned.example.net", "policy": "custom"}]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checker.SetHstsPreloadList(path)
+	if err == nil || err.Error() != path+": HSTS preload list has no force-https entries" {
+		t.Error("expected a list without force-https entries to be refused", err)
+	}
+}
+
+func Test_parseHstsPreloadBundle(t *testing.T) {
+	list, err := parseHstsPreloadBundle([]byte(`{"include_subdomains": ["Example.com"], "host_only": ["example.org"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list["example.com"] || list["example.org"] {
+		t.Error("expected both names with whether they include subdomains", list)
+	}
+
+	_, err = parseHstsPreloadBundle([]byte(`{"include_subdomains": [], "host_only": []}`))
+	if err == nil || err.Error() != "HSTS preload list has no force-https entries" {
+		t.Error("expected an empty bundle to be refused", err)
+	}
+}
+
+func Test_bundledHstsPreloadList(t *testing.T) {
+	checker := NewCheckSSL()
+	list, err := checker.knownHstsPreload()
+	if err != nil {
+		t.Fatal("the bundled HSTS preload list should parse", err)
+	}
+	for host, expected := range map[string]string{
+		"www.example.dev": "dev",
+		"paypal.com":      "paypal.com",
+	} {
+		entry, _ := preloadEntryFor(list, host)
+		assert(t, entry, expected, host)
+	}
+}
+
+func Test_CheckServer_hsts(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	server := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), hstsHeader("max-age=31536000; includeSubDomains; preload"))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetHsts(true)
+	checker.SetHstsMinAge(hstsPreloadMinAge)
+	path := filepath.Join(t.TempDir(), "preload.json")
+	err := os.WriteFile(path, []byte(testHstsPreloadList), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checker.SetHstsPreloadList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startHttpTestServer(t, &checker, redirectTo("https://127.0.0.1/", http.StatusMovedPermanently))
+	actual := checker.CheckServer(server.URL, true)
+
+	if actual.Hsts == nil {
+		t.Fatal("expected an HSTS audit")
+	}
+	if !actual.Passed {
+		t.Error("expected a complete HSTS policy to pass", actual.Err)
+	}
+	if actual.Hsts.MaxAge != 31536000 || !actual.Hsts.IncludeSubDomains || !actual.Hsts.Preload {
+		t.Error("expected the header to be parsed", actual.Hsts)
+	}
+	if !actual.Hsts.HttpsRedirect || actual.Hsts.HttpStatus != 301 || len(actual.Hsts.Findings) != 0 {
+		t.Error("expected http:// to redirect without findings", actual.Hsts)
+	}
+
+	text := actual.AsString(false)
+	for _, expected := range []string{
+		" -> HSTS max-age=31536000; includeSubDomains; preload (365 days)\n",
+		" -> http://127.0.0.1 301 redirects to https://127.0.0.1/\n",
+		" -> 127.0.0.1 is not on the HSTS preload list\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Error("expected text output to include", expected, text)
+		}
+	}
+	if !strings.Contains(actual.AsJson(), `"Hsts":{"Host":"127.0.0.1","Header":"max-age=31536000; includeSubDomains; preload","MaxAge":31536000,`) {
+		t.Error("expected the audit in the JSON output", actual.AsJson())
+	}
+}
+
+func Test_CheckServer_hstsAfterRedirect(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	final := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), hstsHeader("max-age=31536000"))
+	start := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), redirectTo(final.URL+"/", http.StatusMovedPermanently))
+	_, port, _ := net.SplitHostPort(start.Listener.Addr().String())
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetHsts(true)
+	startHttpTestServer(t, &checker, redirectTo("https://127.0.0.1/", http.StatusMovedPermanently))
+	actual := checker.CheckServer("https://localhost:"+port, true)
+
+	if actual.Hsts == nil || actual.Hsts.Host != "127.0.0.1" {
+		t.Fatal("expected the host of the final response to be audited", actual.Hsts)
+	}
+	if actual.Hsts.MaxAge != 31536000 || !actual.Hsts.HttpsRedirect {
+		t.Error("expected the header and the http:// redirect of the same host", actual.Hsts)
+	}
+}
+
+func Test_CheckServer_hstsMinAge(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	server := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), hstsHeader("max-age=300; preload"))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetHsts(true)
+	checker.SetHstsMinAge(86400)
+	startHttpTestServer(t, &checker, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	actual := checker.CheckServer(server.URL, true)
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_ERROR {
+		t.Error("expected a short max-age to fail the check", actual.ExitCode)
+	}
+	assert(t, actual.Err, "HSTS max-age of 300 is below the minimum of 86400", "")
+	assert(t, strings.Join(actual.Hsts.Findings, ", "), "http:// does not redirect to https://, preload needs a max-age of at least 31536000, preload needs includeSubDomains", "")
+	if !strings.Contains(actual.AsString(false), " -> http://127.0.0.1 200 without a redirect\n") {
+		t.Error("expected the missing redirect in the text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_hstsMissing(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	server := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), nil)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetHsts(true)
+	startHttpTestServer(t, &checker, redirectTo("https://127.0.0.1/", http.StatusFound))
+	actual := checker.CheckServer(server.URL, true)
+
+	if !actual.Passed {
+		t.Error("a missing header should only fail with a minimum age", actual.Err)
+	}
+	assert(t, strings.Join(actual.Hsts.Findings, ", "), "no Strict-Transport-Security header", "")
+	if actual.Hsts.PreloadErr != "" && !strings.Contains(actual.AsString(false), " -> HSTS preload list not available: bundled hsts_preload.json") {
+		t.Error("expected a missing preload list to be reported instead of a miss", actual.AsString(false))
+	}
+
+	checker.SetHstsMinAge(0)
+	actual = checker.CheckServer(server.URL, true)
+	assert(t, actual.Err, "missing a valid Strict-Transport-Security header", "")
+	if actual.Passed {
+		t.Error("expected a missing header to fail with a minimum age")
+	}
+}
+
+func Test_checkHttpRedirect_closesConnection(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(redirectTo("https://127.0.0.1/", http.StatusMovedPermanently))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetConnectTo("127.0.0.1:80", server.Listener.Addr().String())
+	audit := &HstsAudit{}
+	checker.checkHttpRedirect(audit, "127.0.0.1")
+
+	if !audit.HttpsRedirect {
+		t.Fatal("expected the redirect to be found", audit)
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Error("expected the connection to port 80 to be closed after the request")
+	}
+}
@@ -0,0 +1,298 @@
+package checkssl
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	recordTypeAlert     = 21
+	recordTypeHandshake = 22
+
+	handshakeTypeClientHello = 1
+	handshakeTypeServerHello = 2
+)
+
+// legacyVersions are offered with hand built ClientHellos, since crypto/tls
+// can not offer SSL v3.0 or the export, RC4, DES and NULL suites.
+var legacyVersions = []uint16{tls.VersionSSL30, tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12}
+
+var alertNames = map[byte]string{
+	0:   "close_notify",
+	10:  "unexpected_message",
+	20:  "bad_record_mac",
+	40:  "handshake_failure",
+	47:  "illegal_parameter",
+	50:  "decode_error",
+	70:  "protocol_version",
+	71:  "insufficient_security",
+	80:  "internal_error",
+	86:  "inappropriate_fallback",
+	112: "unrecognized_name",
+}
+
+// LegacyVersionSupport is what the server answered to a hand built
+// ClientHello at one version, and the insecure suites it picked at it.
+type LegacyVersionSupport struct {
+	Version              uint16
+	Name                 string
+	Accepted             bool
+	Err                  string            `json:",omitempty"`
+	InsecureCipherSuites []CipherSuiteInfo `json:",omitempty"`
+}
+
+// serverHello is the only part of the server's answer the legacy probe reads.
+type serverHello struct {
+	version     uint16
+	cipherSuite uint16
+}
+
+// SetProbeLegacy sends hand built ClientHellos for SSL v3.0 through TLS v1.2
+// with the insecure cipher suites Go can not offer, to prove they are disabled.
+func (a *CheckSSL) SetProbeLegacy(enable bool) {
+	a.probeLegacy = enable
+}
+
+// legacyCipherSuites splits the catalog into every suite that works before
+// TLS v1.3, which finds the versions, and the insecure ones among them.
+func legacyCipherSuites() (all []uint16, insecure []uint16) {
+	for _, suite := range CipherSuiteCatalog() {
+		if suite.KeyExchange == "any" {
+			continue // TLS v1.3 suites
+		}
+		all = append(all, suite.Id)
+		if suite.Strength == CIPHER_INSECURE {
+			insecure = append(insecure, suite.Id)
+		}
+	}
+	return
+}
+
+// checkLegacy offers every suite at each legacy version to see if the server
+// will use it at all, then offers only the insecure suites and removes the
+// one picked each time until the server refuses.
+func (a *CheckSSL) checkLegacy(output *CheckedServer, serverName string, address string, upgrade tlsUpgrade) {
+	all, insecure := legacyCipherSuites()
+	output.LegacyVersions = nil
+	for _, version := range legacyVersions {
+		support := LegacyVersionSupport{Version: version, Name: tls.VersionName(version)}
+		hello, err := a.sendClientHello(serverName, address, upgrade, version, all)
+		switch {
+		case err != nil:
+			support.Err = err.Error()
+		case hello.version != version:
+			support.Err = fmt.Sprintf("server answered with %s", tls.VersionName(hello.version))
+		default:
+			support.Accepted = true
+			support.InsecureCipherSuites = a.legacyInsecureCipherSuites(serverName, address, upgrade, version, insecure)
+		}
+		if len(support.InsecureCipherSuites) > 0 {
+			output.WeakCipherSuites = true
+		}
+		output.LegacyVersions = append(output.LegacyVersions, support)
+	}
+
+	for _, support := range output.LegacyVersions {
+		if support.Accepted && a.failBelowMinTlsVersion(output, support.Version) {
+			return
+		}
+	}
+}
+
+func (a *CheckSSL) legacyInsecureCipherSuites(serverName string, address string, upgrade tlsUpgrade, version uint16, remaining []uint16) (output []CipherSuiteInfo) {
+	for len(remaining) > 0 {
+		hello, err := a.sendClientHello(serverName, address, upgrade, version, remaining)
+		if err != nil || hello.version != version {
+			return
+		}
+		picked := -1
+		for i, id := range remaining {
+			if id == hello.cipherSuite {
+				picked = i
+			}
+		}
+		if picked == -1 {
+			return // the server picked a suite that was not offered
+		}
+		output = append(output, cipherSuiteInfo(remaining[picked]))
+		remaining = append(remaining[:picked:picked], remaining[picked+1:]...)
+	}
+	return
+}
+
+// sendClientHello only reads as far as the ServerHello, no handshake is
+// completed.
+func (a *CheckSSL) sendClientHello(serverName string, address string, upgrade tlsUpgrade, version uint16, suites []uint16) (serverHello, error) {
+	conn, _, err := a.dialTls(address, serverName, upgrade)
+	if err != nil {
+		return serverHello{}, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(clientHello(serverName, version, suites))
+	if err != nil {
+		return serverHello{}, err
+	}
+	return readServerHello(conn)
+}
+
+// clientHello builds a ClientHello record for version. SSL v3.0 hellos have no
+// extensions, since SSL v3.0 servers are not required to understand them.
+func clientHello(serverName string, version uint16, suites []uint16) []byte {
+	random := make([]byte, 32)
+	_, _ = rand.Read(random)
+
+	body := binary.BigEndian.AppendUint16(nil, version)
+	body = append(body, random...)
+	body = append(body, 0) // no session id
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(suites)))
+	for _, suite := range suites {
+		body = binary.BigEndian.AppendUint16(body, suite)
+	}
+	body = append(body, 1, 0) // only the null compression method
+	if version > tls.VersionSSL30 {
+		extensions := helloExtensions(serverName, version)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(extensions)))
+		body = append(body, extensions...)
+	}
+
+	handshake := []byte{handshakeTypeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	recordVersion := uint16(tls.VersionTLS10) // what most clients send, some servers reject anything newer
+	if version == tls.VersionSSL30 {
+		recordVersion = tls.VersionSSL30
+	}
+	record := []byte{recordTypeHandshake}
+	record = binary.BigEndian.AppendUint16(record, recordVersion)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+// helloExtensions are the ones servers need before they will pick an ECDHE
+// suite or the right certificate.
+func helloExtensions(serverName string, version uint16) (output []byte) {
+	if serverName != "" && net.ParseIP(serverName) == nil {
+		name := binary.BigEndian.AppendUint16([]byte{0}, uint16(len(serverName)))
+		name = append(name, serverName...)
+		output = appendExtension(output, 0x0000, binary.BigEndian.AppendUint16(nil, uint16(len(name))), name)
+	}
+
+	groups := []uint16{uint16(tls.X25519), uint16(tls.CurveP256), uint16(tls.CurveP384), uint16(tls.CurveP521)}
+	output = appendExtension(output, 0x000a, uint16List(groups))
+	output = appendExtension(output, 0x000b, []byte{1, 0}) // uncompressed points
+
+	if version >= tls.VersionTLS12 {
+		algorithms := []uint16{0x0401, 0x0501, 0x0601, 0x0403, 0x0503, 0x0603, 0x0804, 0x0805, 0x0806, 0x0201, 0x0203}
+		output = appendExtension(output, 0x000d, uint16List(algorithms))
+	}
+	return appendExtension(output, 0xff01, []byte{0}) // secure renegotiation
+}
+
+func appendExtension(output []byte, extension uint16, data ...[]byte) []byte {
+	length := 0
+	for _, part := range data {
+		length += len(part)
+	}
+	output = binary.BigEndian.AppendUint16(output, extension)
+	output = binary.BigEndian.AppendUint16(output, uint16(length))
+	for _, part := range data {
+		output = append(output, part...)
+	}
+	return output
+}
+
+// uint16List is a list with a two byte length in front.
+func uint16List(values []uint16) []byte {
+	output := binary.BigEndian.AppendUint16(nil, uint16(2*len(values)))
+	for _, value := range values {
+		output = binary.BigEndian.AppendUint16(output, value)
+	}
+	return output
+}
+
+// readServerHello reads records until it has the whole ServerHello, which may
+// be split over several records, or an alert.
+func readServerHello(conn io.Reader) (serverHello, error) {
+	var handshake []byte
+	for records := 0; records < 16; records++ {
+		header := make([]byte, 5)
+		_, err := io.ReadFull(conn, header)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return serverHello{}, errors.New("connection closed without a ServerHello")
+		} else if err != nil {
+			return serverHello{}, err
+		}
+		if header[0]&0x80 != 0 {
+			return serverHello{}, errors.New("server answered with an SSL v2.0 record")
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(header[3:5]))
+		_, err = io.ReadFull(conn, payload)
+		if err != nil {
+			return serverHello{}, fmt.Errorf("reading record: %w", err)
+		}
+
+		switch header[0] {
+		case recordTypeAlert:
+			if len(payload) < 2 {
+				return serverHello{}, errors.New("short alert")
+			}
+			name, ok := alertNames[payload[1]]
+			if !ok {
+				name = fmt.Sprintf("%d", payload[1])
+			}
+			return serverHello{}, fmt.Errorf("alert %s", name)
+		case recordTypeHandshake:
+			handshake = append(handshake, payload...)
+		default:
+			return serverHello{}, fmt.Errorf("unexpected record type %d", header[0])
+		}
+
+		if len(handshake) < 4 {
+			continue
+		}
+		if handshake[0] != handshakeTypeServerHello {
+			return serverHello{}, fmt.Errorf("unexpected handshake message %d", handshake[0])
+		}
+		length := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if len(handshake) < 4+length {
+			continue
+		}
+		return parseServerHello(handshake[4 : 4+length])
+	}
+	return serverHello{}, errors.New("too many records without a ServerHello")
+}
+
+func parseServerHello(body []byte) (serverHello, error) {
+	// version, random, session id length
+	if len(body) < 2+32+1 {
+		return serverHello{}, errors.New("short ServerHello")
+	}
+	sessionId := int(body[34])
+	if len(body) < 35+sessionId+2 {
+		return serverHello{}, errors.New("short ServerHello")
+	}
+	return serverHello{
+		version:     binary.BigEndian.Uint16(body[0:2]),
+		cipherSuite: binary.BigEndian.Uint16(body[35+sessionId:]),
+	}, nil
+}
+
+func (a CheckedServer) legacyLines(color terminalColors) (output string) {
+	for _, support := range a.LegacyVersions {
+		if !support.Accepted {
+			output += fmt.Sprintf(" -> legacy ClientHello for %s refused\n", support.Name)
+			continue
+		}
+		output += fmt.Sprintf(" -> legacy ClientHello accepted with %s\n", getTlsVersion(support.Version, color))
+		for _, suite := range support.InsecureCipherSuites {
+			output += fmt.Sprintf("      %s%s %s - %s%s\n", color.red, suite.Name, suite.Strength, suite.Reason, color.noColor)
+		}
+	}
+	return
+}
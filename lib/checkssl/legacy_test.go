@@ -0,0 +1,208 @@
+package checkssl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeHelloServer answers a ClientHello the way an old server would, without
+// ever finishing the handshake. It picks the first of its preferred suites
+// that the client offered, and answers a version above its highest with its
+// highest, like a real server negotiating down.
+type fakeHelloServer struct {
+	versions  []uint16
+	preferred []uint16
+}
+
+func startFakeHelloServer(t *testing.T, fake fakeHelloServer) string {
+	return startTestServer(t, func(conn net.Conn) {
+		version, offered, err := readClientHello(conn)
+		if err != nil {
+			t.Error("could not read the ClientHello", err)
+			return
+		}
+		highest := fake.versions[len(fake.versions)-1]
+		if version > highest {
+			version = highest
+		}
+		accepted := false
+		for _, supported := range fake.versions {
+			accepted = accepted || supported == version
+		}
+		if !accepted {
+			_, _ = conn.Write(cannedAlert(70))
+			return
+		}
+		for _, suite := range fake.preferred {
+			for _, id := range offered {
+				if id == suite {
+					_, _ = conn.Write(cannedServerHello(version, suite))
+					return
+				}
+			}
+		}
+		_, _ = conn.Write(cannedAlert(40))
+	})
+}
+
+func readClientHello(conn io.Reader) (version uint16, suites []uint16, err error) {
+	header := make([]byte, 5)
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return
+	}
+	record := make([]byte, binary.BigEndian.Uint16(header[3:]))
+	if _, err = io.ReadFull(conn, record); err != nil {
+		return
+	}
+	body := record[4:] // handshake type and length
+	version = binary.BigEndian.Uint16(body)
+	body = body[35+int(body[34]):] // random and session id
+	count := int(binary.BigEndian.Uint16(body)) / 2
+	for i := 0; i < count; i++ {
+		suites = append(suites, binary.BigEndian.Uint16(body[2+2*i:]))
+	}
+	return
+}
+
+func cannedServerHello(version uint16, suite uint16) []byte {
+	body := binary.BigEndian.AppendUint16(nil, version)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0)
+	body = binary.BigEndian.AppendUint16(body, suite)
+	body = append(body, 0)
+	handshake := append([]byte{handshakeTypeServerHello, 0, 0, byte(len(body))}, body...)
+	record := []byte{recordTypeHandshake}
+	record = binary.BigEndian.AppendUint16(record, version)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+func cannedAlert(description byte) []byte {
+	return []byte{recordTypeAlert, 3, 1, 0, 2, 2, description}
+}
+
+func Test_readServerHello_splitRecords(t *testing.T) {
+	hello := cannedServerHello(tls.VersionTLS10, 0x0005)
+	handshake := hello[5:]
+	var split []byte
+	for _, part := range [][]byte{handshake[:10], handshake[10:]} {
+		split = append(split, recordTypeHandshake, 3, 1, 0, byte(len(part)))
+		split = append(split, part...)
+	}
+
+	actual, err := readServerHello(bytes.NewReader(split))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.version != tls.VersionTLS10 || actual.cipherSuite != 0x0005 {
+		t.Error("expected TLS v1.0 with TLS_RSA_WITH_RC4_128_SHA", actual)
+	}
+}
+
+func Test_readServerHello_alert(t *testing.T) {
+	_, err := readServerHello(bytes.NewReader(cannedAlert(40)))
+	if err == nil || err.Error() != "alert handshake_failure" {
+		t.Error("expected the alert to be named", err)
+	}
+	_, err = readServerHello(bytes.NewReader(nil))
+	if err == nil || err.Error() != "connection closed without a ServerHello" {
+		t.Error("expected a closed connection to be reported", err)
+	}
+}
+
+func Test_clientHello(t *testing.T) {
+	version, suites, err := readClientHello(bytes.NewReader(clientHello("example.com", tls.VersionSSL30, []uint16{0x0004, 0x000A})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != tls.VersionSSL30 || len(suites) != 2 || suites[1] != 0x000A {
+		t.Error("expected an SSL v3.0 hello with both suites", version, suites)
+	}
+	if !bytes.Contains(clientHello("example.com", tls.VersionTLS12, nil), []byte("example.com")) {
+		t.Error("expected the server name in a TLS hello")
+	}
+}
+
+func Test_checkLegacy_sslv3(t *testing.T) {
+	address := startFakeHelloServer(t, fakeHelloServer{
+		versions:  []uint16{tls.VersionSSL30, tls.VersionTLS10},
+		preferred: []uint16{0x0005, 0x002F, 0x000A, 0x0003}, // RC4, AES, 3DES, export RC4
+	})
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := CheckedServer{Passed: true}
+	checker.checkLegacy(&actual, "localhost", address, nil)
+
+	if len(actual.LegacyVersions) != 4 {
+		t.Fatal("expected a result for every legacy version", actual.LegacyVersions)
+	}
+	sslv3 := actual.LegacyVersions[0]
+	if !sslv3.Accepted {
+		t.Fatal("expected SSL v3.0 to be accepted", sslv3.Err)
+	}
+	var names []string
+	for _, suite := range sslv3.InsecureCipherSuites {
+		names = append(names, suite.Name)
+	}
+	assert(t, strings.Join(names, " "), "TLS_RSA_WITH_RC4_128_SHA TLS_RSA_WITH_3DES_EDE_CBC_SHA TLS_RSA_EXPORT_WITH_RC4_40_MD5", "")
+	assert(t, actual.LegacyVersions[2].Err, "server answered with TLS 1.0", "")
+	if actual.Passed || actual.ExitCode != RETURNCODE_ERROR || actual.Err != "server accepts SSLv3, which is below the minimum of TLS 1.2" {
+		t.Error("expected SSL v3.0 to fail the check", actual.ExitCode, actual.Err)
+	}
+	if !actual.WeakCipherSuites {
+		t.Error("expected the insecure suites to be flagged")
+	}
+
+	text := actual.AsString(false)
+	for _, expected := range []string{
+		" -> legacy ClientHello accepted with SSL v3.0 () - PLEASE UPGRADE to TLS v1.2\n",
+		"      TLS_RSA_WITH_RC4_128_SHA insecure - RC4 is broken\n",
+		" -> legacy ClientHello for TLS 1.2 refused\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Error("expected text output to include", expected, text)
+		}
+	}
+}
+
+func Test_checkLegacy_modernServer(t *testing.T) {
+	address := startFakeHelloServer(t, fakeHelloServer{
+		versions:  []uint16{tls.VersionTLS12},
+		preferred: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	})
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := CheckedServer{Passed: true}
+	checker.checkLegacy(&actual, "localhost", address, nil)
+
+	assert(t, actual.LegacyVersions[0].Err, "alert protocol_version", "")
+	if !actual.LegacyVersions[3].Accepted || len(actual.LegacyVersions[3].InsecureCipherSuites) != 0 {
+		t.Error("expected TLS v1.2 without insecure suites", actual.LegacyVersions[3])
+	}
+	if !actual.Passed || actual.WeakCipherSuites {
+		t.Error("expected a modern server to pass", actual.Err)
+	}
+}
+
+func Test_CheckServer_legacy(t *testing.T) {
+	address := startVersionServer(t, tls.VersionTLS12)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetProbeLegacy(true)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if !actual.Passed {
+		t.Fatal("expected a Go server to refuse every legacy hello", actual.Err, actual.LegacyVersions)
+	}
+	if len(actual.LegacyVersions) != 4 || actual.LegacyVersions[0].Accepted || !actual.LegacyVersions[3].Accepted {
+		t.Error("expected only TLS v1.2 to be accepted", actual.LegacyVersions)
+	}
+}
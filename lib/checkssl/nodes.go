@@ -0,0 +1,103 @@
+package checkssl
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// lookupIps is swapped out by tests that need a host with several addresses.
+var lookupIps = getAllDnsRecordsFor
+
+// dialContext is used for every connection a check makes. It applies any
+// connect-to overrides, and when checking a single node it sends connections
+// for the target's host to that node's ip. The connection goes through a proxy
+// when one is set or found in the environment.
+func (a *CheckSSL) dialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: time.Duration(a.timeoutSeconds) * time.Second}
+	address = a.connectAddress(address)
+
+	proxy, err := a.proxyFor(address)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		return a.dialThroughProxy(ctx, dialer, proxy, address)
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+func (a *CheckSSL) connectAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	for _, candidate := range []string{address, net.JoinHostPort(host, ""), net.JoinHostPort("", port), ":"} {
+		if connectTo, found := a.connectTo[candidate]; found {
+			toHost, toPort, err := net.SplitHostPort(connectTo)
+			if err != nil {
+				return connectTo
+			}
+			if toHost == "" {
+				toHost = host
+			}
+			if toPort == "" {
+				toPort = port
+			}
+			return net.JoinHostPort(toHost, toPort)
+		}
+	}
+
+	if a.nodeIp != "" && host == a.nodeHost {
+		return net.JoinHostPort(a.nodeIp, port)
+	}
+	return address
+}
+
+// checkEveryIp resolves the target's host and checks each address on its own,
+// keeping the original name for SNI and certificate verification.
+func (a *CheckSSL) checkEveryIp(target string, insecure bool) (output CheckedServer) {
+	single := *a
+	single.checkAllIps = false
+
+	_, host, _ := splitTarget(target)
+	if host == "" || net.ParseIP(host) != nil {
+		return single.CheckServer(target, insecure)
+	}
+	ips := lookupIps(host)
+	if len(ips) == 0 {
+		return single.CheckServer(target, insecure)
+	}
+
+	output.Passed = true
+	for _, ip := range ips {
+		node := single
+		node.nodeHost = host
+		node.nodeIp = ip
+		result := node.CheckServer(target, insecure)
+		result.IpAddress = ip
+
+		output.Target = result.Target
+		output.Nodes = append(output.Nodes, result)
+		if !result.Passed {
+			output.Passed = false
+			if output.ExitCode == RETURNCODE_PASS {
+				output.ExitCode = result.ExitCode
+			}
+		}
+	}
+
+	first := output.Nodes[0]
+	for _, node := range output.Nodes[1:] {
+		if node.leafHash != first.leafHash && len(node.Certs) > 0 && len(first.Certs) > 0 {
+			output.Passed = false
+			output.Err = "not every ip serves the same certificate"
+			break
+		}
+	}
+	if !output.Passed && output.ExitCode == RETURNCODE_PASS {
+		output.ExitCode = RETURNCODE_ERROR
+	}
+	return
+}
@@ -0,0 +1,139 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startTestNodes listens on the same port on 127.0.0.1 and 127.0.0.2, serving
+// one certificate each, and makes every host resolve to both addresses.
+func startTestNodes(t *testing.T, first tls.Certificate, second tls.Certificate) string {
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{first}}))
+	_, port, _ := net.SplitHostPort(address)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		t.Skip("127.0.0.2 is not available for a second node", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	handle := serveTls(&tls.Config{Certificates: []tls.Certificate{second}})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	original := lookupIps
+	lookupIps = func(host string) []string { return []string{"127.0.0.1", "127.0.0.2"} }
+	t.Cleanup(func() { lookupIps = original })
+	return port
+}
+
+func Test_CheckServer_allIpsSameCertificate(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	port := startTestNodes(t, leaf.tlsCertificate(), leaf.tlsCertificate())
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetCheckAllIps(true)
+	actual := checker.CheckServer("tls://localhost:"+port, false)
+
+	if len(actual.Nodes) != 2 {
+		t.Fatal("expected a result for each ip, got", len(actual.Nodes))
+	}
+	assert(t, actual.Nodes[0].IpAddress, "127.0.0.1", "")
+	assert(t, actual.Nodes[1].IpAddress, "127.0.0.2", "")
+	assert(t, actual.Target, "tls://localhost:"+port, "")
+	for _, node := range actual.Nodes {
+		assert(t, node.ServerName, "localhost", "each node should be checked with the original name")
+		if len(node.Certs) != 1 {
+			t.Error("expected each node to report its certificate")
+		}
+	}
+	if strings.Contains(actual.Err, "same certificate") {
+		t.Error("nodes serve the same certificate, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_allIpsDifferentCertificate(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	port := startTestNodes(t, newTestLeaf(t, ca, "localhost").tlsCertificate(), newTestLeaf(t, ca, "localhost").tlsCertificate())
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetCheckAllIps(true)
+	actual := checker.CheckServer("tls://localhost:"+port, false)
+
+	assert(t, actual.Err, "not every ip serves the same certificate", "")
+	if actual.Passed || actual.ExitCode == RETURNCODE_PASS {
+		t.Error("expected different certificates to fail the target")
+	}
+
+	text := actual.AsString(false)
+	if !strings.Contains(text, "[FAIL] 127.0.0.2") || !strings.Contains(text, "not every ip serves the same certificate") {
+		t.Error("expected each node in the text output", text)
+	}
+}
+
+func Test_connectAddress(t *testing.T) {
+	checker := NewCheckSSL()
+	checker.SetConnectTo("example.com:443", "10.0.0.5:443")
+	checker.SetConnectTo("www.example.com:", "10.0.0.6:")
+	checker.SetConnectTo(":8443", "lb.example.net:9443")
+
+	expected := map[string]string{
+		"example.com:443":     "10.0.0.5:443",
+		"example.com:80":      "example.com:80",
+		"www.example.com:993": "10.0.0.6:993",
+		"other.com:8443":      "lb.example.net:9443",
+		"other.com:443":       "other.com:443",
+	}
+	for address, want := range expected {
+		assert(t, checker.connectAddress(address), want, address)
+	}
+}
+
+func Test_CheckServer_connectToHttps(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "example.com")
+	server := startHttpsTestServer(t, leaf.tlsCertificate(), nil)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetConnectTo("example.com:"+port, server.Listener.Addr().String())
+	actual := checker.CheckServer("https://example.com:"+port, false)
+
+	assert(t, actual.ServerName, "example.com", "SNI should still use the logical host")
+	assert(t, actual.IpAddress, "127.0.0.1", "")
+	if len(actual.Certs) != 1 || actual.Certs[0].CommonName != "example.com" {
+		t.Error("expected the fixture certificate, got", actual.Certs, actual.Err)
+	}
+	if !strings.Contains(actual.Err, "unknown authority") {
+		t.Error("expected the chain to be verified for the logical host, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_connectToRawTls(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "ldap.example.com")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetConnectTo("ldap.example.com:636", address)
+	actual := checker.CheckServer("ldap.example.com:636", false)
+
+	assert(t, actual.Target, "tls://ldap.example.com:636", "")
+	assert(t, actual.ServerName, "ldap.example.com", "")
+	if len(actual.Certs) != 1 {
+		t.Error("expected the fixture certificate, got", actual.Err)
+	}
+}
@@ -0,0 +1,372 @@
+package checkssl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	REVOCATION_GOOD    = "good"
+	REVOCATION_REVOKED = "revoked"
+	REVOCATION_UNKNOWN = "unknown"
+
+	ocspResponseSuccessful = 0
+	ocspMaxResponseBytes   = 1 << 20
+)
+
+var (
+	oidOcspBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSha1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+
+	oidSignatureAlgorithms = map[string]x509.SignatureAlgorithm{
+		"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+		"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+		"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+		"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+		"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+		"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+		"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+		"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+		"1.3.101.112":           x509.PureEd25519,
+	}
+
+	crlReasons = map[int]string{
+		0:  "unspecified",
+		1:  "keyCompromise",
+		2:  "cACompromise",
+		3:  "affiliationChanged",
+		4:  "superseded",
+		5:  "cessationOfOperation",
+		6:  "certificateHold",
+		8:  "removeFromCRL",
+		9:  "privilegeWithdrawn",
+		10: "aACompromise",
+	}
+)
+
+// RevocationStatus is what a revocation source said about one certificate.
+type RevocationStatus struct {
+	Status     string
+	RevokedAt  time.Time
+	Reason     string `json:",omitempty"`
+	ThisUpdate time.Time
+	NextUpdate time.Time
+	Source     string
+	Err        string `json:",omitempty"`
+}
+
+// asString is a short note for the end of a certificate's line in text output.
+func (a *RevocationStatus) asString(source string, color terminalColors) string {
+	if a == nil {
+		return ""
+	}
+	switch {
+	case a.Err != "":
+		return fmt.Sprintf(" %s[%s %s: %s]%s", color.yellow, source, a.Status, a.Err, color.noColor)
+	case a.Status == REVOCATION_GOOD:
+		return fmt.Sprintf(" %s[%s %s]%s", color.green, source, a.Status, color.noColor)
+	case a.Status == REVOCATION_REVOKED:
+		return fmt.Sprintf(" %s[%s %s]%s", color.red, source, a.Status, color.noColor)
+	}
+	return fmt.Sprintf(" %s[%s %s]%s", color.yellow, source, a.Status, color.noColor)
+}
+
+// revocation returns the status that says the certificate was revoked, if any.
+func (a CheckCert) revocation() *RevocationStatus {
+	for _, status := range []*RevocationStatus{a.Ocsp, a.Crl} {
+		if status != nil && status.Status == REVOCATION_REVOKED {
+			return status
+		}
+	}
+	return nil
+}
+
+// The ASN.1 structures from RFC 6960
+
+type ocspCertId struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequestEntry struct {
+	Cert ocspCertId
+}
+
+type ocspTbsRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []ocspRequestEntry
+}
+
+type ocspRequest struct {
+	TbsRequest ocspTbsRequest
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TbsResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderId     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertId           ocspCertId
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// SetOcsp turns on OCSP revocation checking of each leaf certificate.
+func (a *CheckSSL) SetOcsp(enable bool) {
+	a.ocsp = enable
+}
+
+// SetOcspResponder sends every OCSP request to responderUrl instead of the
+// responder listed in the certificate.
+func (a *CheckSSL) SetOcspResponder(responderUrl string) {
+	a.ocspResponder = responderUrl
+}
+
+// checkOcsp asks the certificate's OCSP responder about cert.
+func (a *CheckSSL) checkOcsp(cert *x509.Certificate, issuer *x509.Certificate) *RevocationStatus {
+	responder := a.ocspResponder
+	if responder == "" && len(cert.OCSPServer) > 0 {
+		responder = cert.OCSPServer[0]
+	}
+	if responder == "" {
+		return nil
+	}
+	output := &RevocationStatus{Status: REVOCATION_UNKNOWN, Source: responder}
+	if issuer == nil {
+		output.Err = "issuer certificate was not presented"
+		return output
+	}
+
+	request, err := createOcspRequest(cert, issuer)
+	if err != nil {
+		output.Err = err.Error()
+		return output
+	}
+	response, err := a.fetch(http.MethodPost, responder, "application/ocsp-request", request, ocspMaxResponseBytes)
+	if err != nil {
+		output.Err = err.Error()
+		return output
+	}
+
+	parsed, err := parseOcspResponse(response, cert, issuer)
+	if err != nil {
+		output.Err = err.Error()
+		return output
+	}
+	parsed.Source = responder
+	return parsed
+}
+
+// fetch makes a request through the same dialer as the checks, so proxies
+// and connect-to overrides apply to revocation lookups too. Keep-alives are
+// off so no idle connection outlives the request.
+func (a *CheckSSL) fetch(method string, target string, contentType string, body []byte, maxBytes int64) ([]byte, error) {
+	timeout := time.Duration(a.timeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	client := &http.Client{Transport: &http.Transport{DialContext: a.dialContext, DisableKeepAlives: true}}
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", target, response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxBytes))
+}
+
+func newOcspCertId(cert *x509.Certificate, issuer *x509.Certificate) (ocspCertId, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	_, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo)
+	if err != nil {
+		return ocspCertId{}, err
+	}
+
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(publicKeyInfo.PublicKey.RightAlign())
+	return ocspCertId{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSha1, Parameters: asn1.NullRawValue},
+		NameHash:      nameHash[:],
+		IssuerKeyHash: keyHash[:],
+		SerialNumber:  cert.SerialNumber,
+	}, nil
+}
+
+// matches compares every field, a responder answering for several CAs can
+// have the same serial number under different issuers.
+func (a ocspCertId) matches(other ocspCertId) bool {
+	return a.HashAlgorithm.Algorithm.Equal(other.HashAlgorithm.Algorithm) &&
+		bytes.Equal(a.NameHash, other.NameHash) &&
+		bytes.Equal(a.IssuerKeyHash, other.IssuerKeyHash) &&
+		a.SerialNumber != nil && a.SerialNumber.Cmp(other.SerialNumber) == 0
+}
+
+func createOcspRequest(cert *x509.Certificate, issuer *x509.Certificate) ([]byte, error) {
+	certId, err := newOcspCertId(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspRequest{TbsRequest: ocspTbsRequest{RequestList: []ocspRequestEntry{{Cert: certId}}}})
+}
+
+// parseOcspResponse checks the response signature and finds the status of cert in it.
+func parseOcspResponse(der []byte, cert *x509.Certificate, issuer *x509.Certificate) (*RevocationStatus, error) {
+	single, err := findOcspResponse(der, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &RevocationStatus{
+		Status:     REVOCATION_UNKNOWN,
+		ThisUpdate: single.ThisUpdate,
+		NextUpdate: single.NextUpdate,
+	}
+	switch {
+	case bool(single.Good):
+		output.Status = REVOCATION_GOOD
+	case !single.Revoked.RevocationTime.IsZero():
+		output.Status = REVOCATION_REVOKED
+		output.RevokedAt = single.Revoked.RevocationTime
+		output.Reason = crlReasons[int(single.Revoked.Reason)]
+	}
+	if !single.NextUpdate.IsZero() && time.Now().After(single.NextUpdate) {
+		output.Err = "OCSP response is stale, next update was " + single.NextUpdate.Format(dateLayout)
+	}
+	return output, nil
+}
+
+// findOcspResponse checks the response signature and returns the part of it about cert.
+func findOcspResponse(der []byte, cert *x509.Certificate, issuer *x509.Certificate) (ocspSingleResponse, error) {
+	var response ocspResponse
+	_, err := asn1.Unmarshal(der, &response)
+	if err != nil {
+		return ocspSingleResponse{}, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	if response.Status != ocspResponseSuccessful {
+		return ocspSingleResponse{}, fmt.Errorf("OCSP responder returned error status %d", response.Status)
+	}
+	if !response.Response.ResponseType.Equal(oidOcspBasicResponse) {
+		return ocspSingleResponse{}, errors.New("unsupported OCSP response type")
+	}
+
+	var basic ocspBasicResponse
+	_, err = asn1.Unmarshal(response.Response.Response, &basic)
+	if err != nil {
+		return ocspSingleResponse{}, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	err = checkOcspSignature(basic, issuer)
+	if err != nil {
+		return ocspSingleResponse{}, err
+	}
+
+	expected, err := newOcspCertId(cert, issuer)
+	if err != nil {
+		return ocspSingleResponse{}, err
+	}
+	for _, single := range basic.TbsResponseData.Responses {
+		if single.CertId.matches(expected) {
+			return single, nil
+		}
+	}
+	return ocspSingleResponse{}, errors.New("OCSP response does not include the certificate")
+}
+
+// checkOcspSignature accepts responses signed by the issuer itself, or by a
+// responder certificate the issuer delegated OCSP signing to.
+func checkOcspSignature(basic ocspBasicResponse, issuer *x509.Certificate) error {
+	algorithm, found := oidSignatureAlgorithms[basic.SignatureAlgorithm.Algorithm.String()]
+	if !found {
+		return fmt.Errorf("unsupported OCSP signature algorithm %s", basic.SignatureAlgorithm.Algorithm)
+	}
+
+	signer := issuer
+	for _, raw := range basic.Certificates {
+		responder, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return fmt.Errorf("invalid OCSP responder certificate: %w", err)
+		}
+		if responder.CheckSignatureFrom(issuer) == nil && hasExtKeyUsage(responder, x509.ExtKeyUsageOCSPSigning) {
+			signer = responder
+			break
+		}
+	}
+
+	err := signer.CheckSignature(algorithm, basic.TbsResponseData.Raw, basic.Signature.RightAlign())
+	if err != nil {
+		return fmt.Errorf("OCSP response signature is invalid: %w", err)
+	}
+	return nil
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, found := range cert.ExtKeyUsage {
+		if found == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// issuerOf returns the next certificate in the presented chain if it signed cert.
+func issuerOf(chain []*x509.Certificate, index int) *x509.Certificate {
+	if index+1 < len(chain) && chain[index].CheckSignatureFrom(chain[index+1]) == nil {
+		return chain[index+1]
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package checkssl
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var oidEcdsaWithSha256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+// newOcspResponse builds a response for cert signed by signer, which is
+// either the issuer or a delegated responder.
+func newOcspResponse(t *testing.T, cert *x509.Certificate, issuer *testCertificate, signer *testCertificate, single ocspSingleResponse) []byte {
+	certId, err := newOcspCertId(cert, issuer.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single.CertId = certId
+	if single.ThisUpdate.IsZero() {
+		single.ThisUpdate = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	}
+
+	tbs, err := asn1.Marshal(ocspResponseData{
+		RawResponderId: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: signer.Cert.RawSubject},
+		ProducedAt:     time.Now().UTC().Truncate(time.Second),
+		Responses:      []ocspSingleResponse{single},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	signature, err := signer.Key.Sign(rand.Reader, digest[:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic := ocspBasicResponse{
+		TbsResponseData:    ocspResponseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidEcdsaWithSha256},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	}
+	if signer != issuer {
+		basic.Certificates = []asn1.RawValue{{FullBytes: signer.Cert.Raw}}
+	}
+	basicBytes, err := asn1.Marshal(basic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := asn1.Marshal(ocspResponse{Response: ocspResponseBytes{ResponseType: oidOcspBasicResponse, Response: basicBytes}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func startOcspResponder(t *testing.T, response []byte, requests chan []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if requests != nil {
+			requests <- body
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_parseOcspResponse_good(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	nextUpdate := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	response := newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{Good: true, NextUpdate: nextUpdate})
+
+	actual, err := parseOcspResponse(response, leaf.Cert, ca.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, actual.Status, REVOCATION_GOOD, "")
+	assert(t, actual.Err, "", "")
+	if !actual.NextUpdate.Equal(nextUpdate) {
+		t.Error("expected next update to be parsed, got", actual.NextUpdate)
+	}
+}
+
+func Test_parseOcspResponse_revoked(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	revokedAt := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	response := newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{Revoked: ocspRevokedInfo{RevocationTime: revokedAt, Reason: 1}})
+
+	actual, err := parseOcspResponse(response, leaf.Cert, ca.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, actual.Status, REVOCATION_REVOKED, "")
+	assert(t, actual.Reason, "keyCompromise", "")
+	if !actual.RevokedAt.Equal(revokedAt) {
+		t.Error("expected the revocation time, got", actual.RevokedAt)
+	}
+}
+
+func Test_parseOcspResponse_delegatedResponder(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	responder := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test OCSP Responder"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, ca)
+	response := newOcspResponse(t, leaf.Cert, ca, responder, ocspSingleResponse{Good: true})
+
+	actual, err := parseOcspResponse(response, leaf.Cert, ca.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, actual.Status, REVOCATION_GOOD, "")
+}
+
+func Test_parseOcspResponse_badSignature(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	response := newOcspResponse(t, leaf.Cert, ca, newTestCA(t, "Other CA"), ocspSingleResponse{Good: true})
+
+	_, err := parseOcspResponse(response, leaf.Cert, ca.Cert)
+	if err == nil || !strings.Contains(err.Error(), "signature is invalid") {
+		t.Error("expected a response signed by someone else to be rejected, got", err)
+	}
+}
+
+func Test_parseOcspResponse_otherIssuer(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	// the same serial number under another CA, signed by the right one
+	response := newOcspResponse(t, leaf.Cert, newTestCA(t, "Other CA"), ca, ocspSingleResponse{Good: true})
+
+	_, err := parseOcspResponse(response, leaf.Cert, ca.Cert)
+	if err == nil || err.Error() != "OCSP response does not include the certificate" {
+		t.Error("expected a response for another issuer to be ignored, got", err)
+	}
+}
+
+func Test_fetch_closesConnection(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	_, err := checker.fetch(http.MethodGet, server.URL, "", nil, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Error("expected the connection to be closed after the request")
+	}
+}
+
+func Test_parseOcspResponse_stale(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	response := newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{
+		Good:       true,
+		ThisUpdate: time.Now().Add(-72 * time.Hour).UTC().Truncate(time.Second),
+		NextUpdate: time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second),
+	})
+
+	actual, err := parseOcspResponse(response, leaf.Cert, ca.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(actual.Err, "stale") {
+		t.Error("expected a response past its next update to be flagged, got", actual.Err)
+	}
+}
+
+func testOcspServer(t *testing.T, single ocspSingleResponse) (CheckedServer, chan []byte) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	requests := make(chan []byte, 4)
+	responder := startOcspResponder(t, newOcspResponse(t, leaf.Cert, ca, ca, single), requests)
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate(ca)}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetOcsp(true)
+	checker.SetOcspResponder(responder.URL)
+	return checker.CheckServer("tls://"+address, true), requests
+}
+
+func Test_CheckServer_ocspGood(t *testing.T) {
+	actual, requests := testOcspServer(t, ocspSingleResponse{Good: true})
+
+	if !actual.Passed || actual.Certs[0].Ocsp == nil || actual.Certs[0].Ocsp.Status != REVOCATION_GOOD {
+		t.Fatal("expected a good OCSP status to pass", actual.Certs[0].Ocsp)
+	}
+	if actual.Certs[1].Ocsp != nil {
+		t.Error("only the leaf should be checked")
+	}
+
+	var request ocspRequest
+	if _, err := asn1.Unmarshal(<-requests, &request); err != nil || len(request.TbsRequest.RequestList) != 1 {
+		t.Error("expected the responder to get a valid request", err)
+	}
+	if !strings.Contains(actual.AsString(false), "[OCSP good]") {
+		t.Error("expected the OCSP status in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_ocspRevoked(t *testing.T) {
+	actual, _ := testOcspServer(t, ocspSingleResponse{Revoked: ocspRevokedInfo{RevocationTime: time.Now().Add(-time.Hour).UTC().Truncate(time.Second), Reason: 4}})
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_REVOKED {
+		t.Error("expected a revoked certificate to fail with RETURNCODE_REVOKED, got", actual.ExitCode)
+	}
+	if !strings.Contains(actual.AsString(false), "was REVOKED on") || !strings.Contains(actual.AsString(false), "(superseded)") {
+		t.Error("expected the revocation in text output", actual.AsString(false))
+	}
+	if !strings.HasPrefix(strings.SplitN(actual.AsCsv(), ",", 3)[1], "REVOKED") {
+		t.Error("expected the csv result to be REVOKED", actual.AsCsv())
+	}
+}
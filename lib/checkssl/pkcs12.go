@@ -0,0 +1,50 @@
+package checkssl
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// parsePkcs12 returns the private key and the certificate that goes with it,
+// followed by any other certificates in the file, as a tls.Certificate.
+func parsePkcs12(data []byte, password string) (tls.Certificate, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return tls.Certificate{}, errors.New("PKCS#12 password is incorrect")
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("not a usable PKCS#12 file: %w", err)
+	}
+	return pkcs12Certificate(key, append([]*x509.Certificate{cert}, caCerts...))
+}
+
+// pkcs12Certificate puts the certificate matching key first, since files
+// often list the CA certificates before it.
+func pkcs12Certificate(key crypto.PrivateKey, certs []*x509.Certificate) (tls.Certificate, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return tls.Certificate{}, errors.New("PKCS#12 file has no private key")
+	}
+	output := tls.Certificate{PrivateKey: key}
+	for i, cert := range certs {
+		publicKey, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+		if ok && publicKey.Equal(signer.Public()) {
+			output.Leaf = cert
+			output.Certificate = append(output.Certificate, cert.Raw)
+			certs = append(certs[:i:i], certs[i+1:]...)
+			break
+		}
+	}
+	if output.Leaf == nil {
+		return tls.Certificate{}, errors.New("PKCS#12 file has no certificate for its private key")
+	}
+	for _, cert := range certs {
+		output.Certificate = append(output.Certificate, cert.Raw)
+	}
+	return output, nil
+}
@@ -0,0 +1,179 @@
+package checkssl
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tunnel connects client to address and copies bytes both ways until either side closes.
+func tunnel(client net.Conn, address string) {
+	upstream, err := net.Dial("tcp", address)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() { _, _ = io.Copy(upstream, client) }()
+	_, _ = io.Copy(client, upstream)
+}
+
+func startHttpConnectProxy(t *testing.T, requests chan *http.Request) string {
+	return startTestServer(t, func(conn net.Conn) {
+		request, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- request
+		if request.Method != http.MethodConnect || request.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		tunnel(conn, request.Host)
+	})
+}
+
+func startSocks5Proxy(t *testing.T, requests chan string) string {
+	return startTestServer(t, func(conn net.Conn) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		methods := make([]byte, header[1])
+		if _, err := io.ReadFull(conn, methods); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte{socks5Version, socks5AuthNone})
+
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		var host string
+		switch request[3] {
+		case socks5AddressIpv4:
+			ip := make(net.IP, net.IPv4len)
+			_, _ = io.ReadFull(conn, ip)
+			host = ip.String()
+		case socks5AddressDomain:
+			length := make([]byte, 1)
+			_, _ = io.ReadFull(conn, length)
+			name := make([]byte, length[0])
+			_, _ = io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			return
+		}
+		portBytes := make([]byte, 2)
+		_, _ = io.ReadFull(conn, portBytes)
+		port := binary.BigEndian.Uint16(portBytes)
+		requests <- host + ":" + strconv.Itoa(int(port))
+
+		_, _ = conn.Write([]byte{socks5Version, socks5ReplySucceeded, 0, socks5AddressIpv4, 127, 0, 0, 1, 0, 0})
+		tunnel(conn, "127.0.0.1:"+strconv.Itoa(int(port)))
+	})
+}
+
+func Test_CheckServer_httpConnectProxy(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+	requests := make(chan *http.Request, 4)
+	proxy := startHttpConnectProxy(t, requests)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	if err := checker.SetProxy("http://user:pass@" + proxy); err != nil {
+		t.Fatal(err)
+	}
+	actual := checker.CheckServer("tls://"+address, false)
+
+	request := <-requests
+	assert(t, request.Host, address, "the proxy should be asked to connect to the target")
+	if len(actual.Certs) != 1 {
+		t.Error("expected the certificate through the proxy, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_httpConnectProxyRefused(t *testing.T) {
+	proxy := startHttpConnectProxy(t, make(chan *http.Request, 4))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	_ = checker.SetProxy("http://" + proxy)
+	actual := checker.CheckServer("tls://localhost:636", false)
+	assert(t, actual.Err, "proxy "+proxy+": CONNECT localhost:636 failed: 407 Proxy Authentication Required", "")
+}
+
+func Test_CheckServer_socks5Proxy(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	server := startHttpsTestServer(t, leaf.tlsCertificate(), nil)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	requests := make(chan string, 4)
+	proxy := startSocks5Proxy(t, requests)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	_ = checker.SetProxy("socks5://" + proxy)
+	actual := checker.CheckServer("https://localhost:"+port, false)
+
+	assert(t, <-requests, "127.0.0.1:"+port, "the host name should be resolved before asking the proxy")
+	assert(t, actual.IpAddress, "127.0.0.1", "the address the proxy was sent is the server's")
+	if len(actual.Certs) != 1 {
+		t.Error("expected the certificate through the proxy, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_socks5hProxy(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "example.com")
+	server := startHttpsTestServer(t, leaf.tlsCertificate(), nil)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	requests := make(chan string, 4)
+	proxy := startSocks5Proxy(t, requests)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	_ = checker.SetProxy("socks5h://" + proxy)
+	actual := checker.CheckServer("https://example.com:"+port, false)
+
+	assert(t, <-requests, "example.com:"+port, "the host name should be resolved by the proxy")
+	assert(t, actual.ServerName, "example.com", "")
+	assert(t, actual.IpAddress, "", "the proxy's address is not the server's")
+	if len(actual.Certs) != 1 {
+		t.Error("expected the certificate through the proxy, got", actual.Err)
+	}
+	if strings.Contains(actual.AsString(false), " => ") {
+		t.Error("expected no ip address in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_rawTlsProxyIpAddress(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+	_, port, _ := net.SplitHostPort(address)
+	requests := make(chan *http.Request, 4)
+	proxy := startHttpConnectProxy(t, requests)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	_ = checker.SetProxy("http://user:pass@" + proxy)
+	actual := checker.CheckServer("tls://localhost:"+port, true)
+
+	assert(t, (<-requests).Host, "localhost:"+port, "")
+	assert(t, actual.IpAddress, "", "the proxy's address is not the server's")
+}
+
+func Test_SetProxy_invalid(t *testing.T) {
+	checker := NewCheckSSL()
+	if checker.SetProxy("ftp://proxy:21") == nil {
+		t.Error("expected an unsupported scheme to be rejected")
+	}
+	if checker.SetProxy("socks5://") == nil {
+		t.Error("expected a proxy without a host to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+package checkssl
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SCHEME_TLS        = "tls"
+	SCHEME_SMTP       = "smtp"
+	SCHEME_SUBMISSION = "submission"
+	SCHEME_IMAP       = "imap"
+	SCHEME_POP3       = "pop3"
+	SCHEME_POSTGRES   = "postgres"
+	SCHEME_MYSQL      = "mysql"
+	SCHEME_LDAP       = "ldap"
+	SCHEME_FTP        = "ftp"
+	SCHEME_XMPP       = "xmpp"
+
+	defaultTlsPort = "443"
+)
+
+// tlsUpgrade runs the plaintext part of a protocol that switches to TLS part
+// way through the connection, leaving conn ready for the TLS handshake. The
+// serverName is the name the service is known by, not the host we dialed.
+type tlsUpgrade func(conn net.Conn, serverName string) error
+
+type tlsScheme struct {
+	defaultPort string
+	upgrade     tlsUpgrade
+}
+
+// tlsSchemes are the target schemes that are checked without an HTTP request.
+var tlsSchemes = map[string]tlsScheme{
+	SCHEME_TLS:        {defaultPort: defaultTlsPort},
+	SCHEME_SMTP:       {defaultPort: "25", upgrade: startTlsSmtp},
+	SCHEME_SUBMISSION: {defaultPort: "587", upgrade: startTlsSmtp},
+	SCHEME_IMAP:       {defaultPort: "143", upgrade: startTlsImap},
+	SCHEME_POP3:       {defaultPort: "110", upgrade: startTlsPop3},
+	SCHEME_POSTGRES:   {defaultPort: "5432", upgrade: startTlsPostgres},
+	"postgresql":      {defaultPort: "5432", upgrade: startTlsPostgres},
+	SCHEME_MYSQL:      {defaultPort: "3306", upgrade: startTlsMysql},
+	SCHEME_LDAP:       {defaultPort: "389", upgrade: startTlsLdap},
+	SCHEME_FTP:        {defaultPort: "21", upgrade: startTlsFtp},
+	SCHEME_XMPP:       {defaultPort: "5222", upgrade: startTlsXmpp},
+}
+
+// tlsPorts are the well known ports of services that speak TLS but not HTTP.
+// A target given as host:port without a scheme is only checked with a raw TLS
+// handshake when its port is one of these, anything else gets an HTTPS request.
+var tlsPorts = map[string]bool{
+	"465":  true, // smtps
+	"636":  true, // ldaps
+	"853":  true, // dns over tls
+	"989":  true, // ftps data
+	"990":  true, // ftps
+	"993":  true, // imaps
+	"995":  true, // pop3s
+	"5061": true, // sips
+	"5223": true, // xmpp over tls
+	"5671": true, // amqps
+	"6697": true, // ircs
+	"8883": true, // mqtts
+	"9093": true, // kafka
+}
+
+// splitTarget breaks a target such as "tls://example.com:636/path" into its
+// scheme, host and port. The port is left blank if the target did not have one.
+func splitTarget(target string) (scheme string, host string, port string) {
+	if index := strings.Index(target, "://"); index >= 0 {
+		scheme = strings.ToLower(target[:index])
+		target = target[index+3:]
+	}
+	if index := strings.IndexAny(target, "/?#"); index >= 0 {
+		target = target[:index]
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host = strings.Trim(target, "[]")
+		port = ""
+	}
+	return
+}
+
+// targetDomain returns the ?domain= option of a target, for services like XMPP
+// whose certificate is for a domain that differs from the host we connect to.
+func targetDomain(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return parsed.Query().Get("domain")
+}
+
+func (a *CheckSSL) shouldUseRawTls(target string) bool {
+	scheme, host, port := splitTarget(target)
+	if host == "" {
+		return false
+	}
+	if _, found := tlsSchemes[scheme]; a.rawTls || found {
+		return true
+	}
+	return scheme == "" && tlsPorts[port]
+}
+
+func (a *CheckSSL) checkRawTls(target string, insecure bool) (output CheckedServer) {
+	scheme, host, port := splitTarget(target)
+	protocol, found := tlsSchemes[scheme]
+	if !found {
+		scheme, protocol = SCHEME_TLS, tlsSchemes[SCHEME_TLS]
+	}
+	if port == "" {
+		port = protocol.defaultPort
+	}
+
+	output.Target = scheme + "://" + net.JoinHostPort(host, port)
+	output.Passed = true
+
+	serverName := host
+	if domain := targetDomain(target); domain != "" {
+		serverName = domain
+		output.Target += "?domain=" + domain
+	}
+
+	state, ip, err := a.tlsHandshake(a.tlsConfig(serverName, insecure, &output), net.JoinHostPort(host, port), protocol.upgrade)
+	output.IpAddress = ip
+	if err != nil {
+		if !insecure && !isTimeout(err) {
+			output = a.checkRawTls(target, true)
+		}
+		output.Err = a.trustError(err)
+		output.Passed = false
+		output.ExitCode = RETURNCODE_ERROR
+		return
+	}
+
+	if !insecure {
+		output.TrustStore = a.trustStore()
+	}
+	a.checkConnectionState(&output, state, serverName)
+	if a.probeTlsVersions {
+		a.checkTlsVersions(&output, serverName, net.JoinHostPort(host, port), protocol.upgrade)
+	}
+	if a.probeCipherSuites {
+		a.checkCipherSuites(&output, serverName, net.JoinHostPort(host, port), protocol.upgrade)
+	}
+	if a.probeLegacy {
+		a.checkLegacy(&output, serverName, net.JoinHostPort(host, port), protocol.upgrade)
+	}
+	if a.simulateClients {
+		a.checkClients(&output, serverName, net.JoinHostPort(host, port), protocol.upgrade)
+	}
+	return
+}
+
+// tlsHandshake connects to address, runs the protocol upgrade if there is one,
+// and completes a TLS handshake with config without sending any application
+// data. It returns the connection state and remote ip.
+func (a *CheckSSL) tlsHandshake(config *tls.Config, address string, upgrade tlsUpgrade) (*tls.ConnectionState, string, error) {
+	conn, ip, err := a.dialTls(address, config.ServerName, upgrade)
+	if err != nil {
+		return nil, ip, err
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.Handshake()
+	if err != nil {
+		return nil, ip, err
+	}
+	state := tlsConn.ConnectionState()
+	return &state, ip, nil
+}
+
+// dialTls returns a connection that is ready for the first TLS record, with
+// the timeout already applied.
+func (a *CheckSSL) dialTls(address string, serverName string, upgrade tlsUpgrade) (net.Conn, string, error) {
+	timeout := time.Duration(a.timeoutSeconds) * time.Second
+
+	conn, err := a.dialContext(context.Background(), "tcp", address)
+	if err != nil {
+		return nil, "", err
+	}
+	ip := remoteIp(conn)
+
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return nil, ip, err
+	}
+
+	if upgrade != nil {
+		err = upgrade(conn, serverName)
+		if err != nil {
+			conn.Close()
+			return nil, ip, err
+		}
+	}
+	return conn, ip, nil
+}
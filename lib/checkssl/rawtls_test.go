@@ -0,0 +1,87 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func Test_splitTarget(t *testing.T) {
+	scheme, host, port := splitTarget("TLS://ldap.example.com:636/ignored")
+	assert(t, scheme, "tls", "")
+	assert(t, host, "ldap.example.com", "")
+	assert(t, port, "636", "")
+
+	scheme, host, port = splitTarget("[::1]:993")
+	assert(t, scheme, "", "")
+	assert(t, host, "::1", "")
+	assert(t, port, "993", "")
+
+	scheme, host, port = splitTarget("https://checkssl.org")
+	assert(t, scheme, "https", "")
+	assert(t, host, "checkssl.org", "")
+	assert(t, port, "", "")
+}
+
+func Test_shouldUseRawTls(t *testing.T) {
+	checker := NewCheckSSL()
+	expected := map[string]bool{
+		"":                         false,
+		"checkssl.org":             false,
+		"checkssl.org:443":         false,
+		"checkssl.org:8443":        false,
+		"checkssl.org:80":          false,
+		"checkssl.org:8080":        false,
+		"checkssl.org:12345":       false,
+		"https://checkssl.org:636": false,
+		"checkssl.org:636":         true,
+		"tls://checkssl.org":       true,
+		"imap.example.com:993":     true,
+	}
+	for target, want := range expected {
+		if checker.shouldUseRawTls(target) != want {
+			t.Error("shouldUseRawTls", target, "expected", want)
+		}
+	}
+
+	checker.SetRawTls(true)
+	if !checker.shouldUseRawTls("checkssl.org") {
+		t.Error("SetRawTls(true) should force raw TLS for every target")
+	}
+}
+
+func Test_CheckServer_rawTls(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, false)
+
+	assert(t, actual.Target, "tls://"+address, "")
+	assert(t, actual.IpAddress, "127.0.0.1", "")
+	assert(t, actual.HttpVersion, "", "raw TLS should never report an HTTP version")
+	assert(t, actual.ServerInfo, "", "raw TLS should never report server info")
+	if len(actual.Certs) != 1 || actual.Certs[0].CommonName != "localhost" {
+		t.Fatal("expected the leaf certificate to be collected, got", actual.Certs)
+	}
+	if actual.TlsVersion != tls.VersionTLS13 || actual.TlsAlgorithm == 0 {
+		t.Error("expected the negotiated version and cipher to be recorded", actual.TlsVersion, actual.TlsAlgorithm)
+	}
+	if actual.Passed || !strings.Contains(actual.Err, "x509") {
+		t.Error("expected an untrusted CA to fail verification, got", actual.Err)
+	}
+	if !strings.Contains(actual.AsString(false), " -> TLS v1.3") {
+		t.Error("expected the TLS version line in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_rawTlsConnectionRefused(t *testing.T) {
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://127.0.0.1:1", false)
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_ERROR {
+		t.Error("expected a closed port to fail with RETURNCODE_ERROR")
+	}
+}
@@ -0,0 +1,83 @@
+package checkssl
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const DEFAULT_MAX_REDIRECTS = 10
+
+// RedirectHop is one response in a chain of redirects, including the final one.
+type RedirectHop struct {
+	Url        string
+	StatusCode int
+	IpAddress  string
+	Certs      []CheckCert
+}
+
+// SetMaxRedirects sets how many redirects are followed before the check
+// fails. Zero stops at the first response without following any redirect.
+func (a *CheckSSL) SetMaxRedirects(maxRedirects int) {
+	a.maxRedirects = maxRedirects
+}
+
+// checkRedirect records each redirect response as a hop, evaluating the
+// certificates the server presented for it.
+func (a *CheckSSL) checkRedirect(output *CheckedServer) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if a.maxRedirects <= 0 {
+			return http.ErrUseLastResponse
+		}
+		a.recordHop(output, req.Response)
+		if len(via) > a.maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", a.maxRedirects)
+		}
+		return nil
+	}
+}
+
+func (a *CheckSSL) recordHop(output *CheckedServer, response *http.Response) {
+	if response == nil {
+		return
+	}
+	hop := RedirectHop{
+		Url:        response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		IpAddress:  output.IpAddress,
+	}
+	if response.TLS != nil {
+		certs, exitCode := a.checkCertificates(response.TLS.PeerCertificates)
+		hop.Certs = certs
+		if exitCode > RETURNCODE_PASS {
+			output.ExitCode = exitCode
+			output.Passed = false
+		}
+	}
+	output.Redirects = append(output.Redirects, hop)
+}
+
+// finalHop is the response the redirects ended on. Its certificates were
+// already evaluated by the main check, so they are reused instead of checked
+// again.
+func (a CheckedServer) finalHop(response *http.Response) RedirectHop {
+	return RedirectHop{
+		Url:        response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		IpAddress:  a.IpAddress,
+		Certs:      a.Certs,
+	}
+}
+
+func (a RedirectHop) asString(color terminalColors) (output string) {
+	output = fmt.Sprintf(" -> %d %s => %s", a.StatusCode, a.Url, a.IpAddress)
+	for i, cert := range a.Certs {
+		if i == 0 && cert.IsInvalid {
+			output += fmt.Sprintf(" %s%s expired on %s%s", color.red, cert.CommonName, displayDate(cert.ValidNotAfter), color.noColor)
+		} else if i == 0 {
+			output += fmt.Sprintf(" %s expires on %s", cert.CommonName, displayDate(cert.ValidNotAfter))
+		} else if cert.IsInvalid {
+			output += fmt.Sprintf(" %s(%s expired)%s", color.red, cert.CommonName, color.noColor)
+		}
+	}
+	return output + "\n"
+}
@@ -0,0 +1,115 @@
+package checkssl
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func redirectTo(location string, code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, location, code)
+	})
+}
+
+func Test_CheckServer_redirectHops(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	expiringSoon := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "apex.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		NotAfter:    time.Now().Add(2 * 24 * time.Hour),
+	}, ca)
+
+	final := http.NewServeMux()
+	final.Handle("/", redirectTo("/final", http.StatusFound))
+	final.Handle("/final", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	www := startHttpsTestServer(t, newTestLeaf(t, ca, "www.example.com").tlsCertificate(), final)
+	apex := startHttpsTestServer(t, expiringSoon.tlsCertificate(), redirectTo(www.URL, http.StatusMovedPermanently))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetThreshold(time.Now().Add(5 * 24 * time.Hour))
+	actual := checker.CheckServer(apex.URL, true)
+
+	if len(actual.Redirects) != 3 {
+		t.Fatal("expected 2 redirects and the final response, got", actual.Redirects)
+	}
+	assert(t, actual.Redirects[0].Url, apex.URL, "")
+	assert(t, actual.Redirects[1].Url, www.URL, "")
+	assert(t, actual.Redirects[2].Url, www.URL+"/final", "")
+	if actual.Redirects[0].StatusCode != 301 || actual.Redirects[1].StatusCode != 302 || actual.Redirects[2].StatusCode != 200 {
+		t.Error("expected each hop's status code", actual.Redirects)
+	}
+	assert(t, actual.Redirects[0].IpAddress, "127.0.0.1", "")
+	if !actual.Redirects[0].Certs[0].IsInvalid || actual.Redirects[1].Certs[0].IsInvalid {
+		t.Error("expected only the apex certificate to fail the threshold", actual.Redirects)
+	}
+	if actual.Passed || actual.ExitCode != RETURNCODE_THRESHOLDFAIL {
+		t.Error("expected a bad certificate on any hop to fail the target, got", actual.ExitCode)
+	}
+
+	text := actual.AsString(false)
+	if !strings.Contains(text, " -> 301 "+apex.URL+" => 127.0.0.1 apex.example.com expired on") {
+		t.Error("expected the hops in the text output", text)
+	}
+	if !strings.Contains(actual.AsJson(), `"Redirects":[{"Url":"`+apex.URL) {
+		t.Error("expected the hops in the json output", actual.AsJson())
+	}
+}
+
+func Test_CheckServer_maxRedirects(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	server := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), redirectTo("/", http.StatusFound))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetMaxRedirects(2)
+	actual := checker.CheckServer(server.URL, true)
+
+	assert(t, actual.Err, "stopped after 2 redirects", "")
+	if actual.Passed || len(actual.Redirects) != 3 {
+		t.Error("expected the loop to fail after 2 redirects, got", len(actual.Redirects))
+	}
+}
+
+func Test_CheckServer_noRedirects(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	server := startHttpsTestServer(t, newTestLeaf(t, ca, "localhost").tlsCertificate(ca), redirectTo("/", http.StatusFound))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetMaxRedirects(0)
+	actual := checker.CheckServer(server.URL, true)
+
+	if !actual.Passed || len(actual.Redirects) != 0 || len(actual.Certs) != 2 {
+		t.Error("expected the first response to be checked without following it, got", actual.Err, actual.Redirects)
+	}
+}
+
+func Test_CheckServer_redirectFinalHopCheckedOnce(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	requests := make(chan []byte, 4)
+	responder := startOcspResponder(t, newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{Good: true}), requests)
+	mux := http.NewServeMux()
+	mux.Handle("/", redirectTo("/final", http.StatusFound))
+	mux.Handle("/final", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server := startHttpsTestServer(t, leaf.tlsCertificate(ca), mux)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetOcsp(true)
+	checker.SetOcspResponder(responder.URL)
+	actual := checker.CheckServer(server.URL, true)
+
+	if len(actual.Redirects) != 2 || len(actual.Redirects[1].Certs) != 2 || actual.Redirects[1].Certs[0].Ocsp == nil {
+		t.Fatal("expected the final hop to have the checked certificates", actual.Redirects)
+	}
+	if len(requests) != 2 {
+		t.Error("expected one OCSP request for the redirect and one for the final response, got", len(requests))
+	}
+}
@@ -0,0 +1,125 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+// the TLS Feature extension from RFC 7633, which holds the Must-Staple flag
+var oidTlsFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+const tlsFeatureStatusRequest = 5
+
+// checkStaple evaluates the OCSP response stapled to the handshake. A stale,
+// invalid or revoked staple fails the check, and so does a Must-Staple
+// certificate served without a good staple.
+func (a *CheckSSL) checkStaple(output *CheckedServer, state *tls.ConnectionState) {
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	leaf := state.PeerCertificates[0]
+	output.MustStaple = isMustStaple(leaf)
+
+	if len(state.OCSPResponse) > 0 {
+		output.Stapled = true
+		output.Staple = &RevocationStatus{Status: REVOCATION_UNKNOWN, Source: "stapled"}
+		issuer := leafIssuer(state)
+		if issuer == nil {
+			output.Staple.Err = "issuer certificate was not presented"
+		} else if staple, err := parseOcspResponse(state.OCSPResponse, leaf, issuer); err != nil {
+			output.Staple.Err = err.Error()
+		} else {
+			staple.Source = output.Staple.Source
+			output.Staple = staple
+		}
+	}
+
+	failure, exitCode := "", RETURNCODE_ERROR
+	switch {
+	case output.Staple != nil && output.Staple.Status == REVOCATION_REVOKED:
+		failure, exitCode = "stapled OCSP response says the certificate was revoked", RETURNCODE_REVOKED
+	case output.Staple != nil && output.Staple.Err != "":
+		failure = "stapled OCSP response is not valid: " + output.Staple.Err
+	case output.MustStaple && !output.Stapled:
+		failure = "certificate requires OCSP stapling (Must-Staple) but no staple was sent"
+	case output.MustStaple && output.Staple.Status != REVOCATION_GOOD:
+		failure = "certificate requires OCSP stapling (Must-Staple) but the staple status is " + output.Staple.Status
+	default:
+		return
+	}
+	if output.Err == "" {
+		output.Err = failure
+	}
+	// a revocation outranks an earlier failure, anything else keeps it
+	if output.ExitCode == RETURNCODE_PASS || exitCode == RETURNCODE_REVOKED {
+		output.ExitCode = exitCode
+	}
+	output.Passed = false
+}
+
+// leafIssuer returns the issuer of the leaf, preferring the one the server
+// sent and falling back to the one found while verifying the chain.
+func leafIssuer(state *tls.ConnectionState) *x509.Certificate {
+	if issuer := issuerOf(state.PeerCertificates, 0); issuer != nil {
+		return issuer
+	}
+	for _, chain := range state.VerifiedChains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	return nil
+}
+
+func isMustStaple(cert *x509.Certificate) bool {
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(oidTlsFeature) {
+			continue
+		}
+		var features []int
+		_, err := asn1.Unmarshal(extension.Value, &features)
+		if err != nil {
+			return false
+		}
+		for _, feature := range features {
+			if feature == tlsFeatureStatusRequest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stapleLine is shown in text output when a staple was sent or required.
+func (a CheckedServer) stapleLine(color terminalColors) string {
+	switch {
+	case a.Staple == nil && a.MustStaple:
+		return fmt.Sprintf(" -> %sno OCSP staple, but the certificate is Must-Staple%s\n", color.red, color.noColor)
+	case a.Staple == nil:
+		return ""
+	case a.Staple.Err != "":
+		return fmt.Sprintf(" -> %sOCSP staple %s: %s%s\n", color.red, a.Staple.Status, a.Staple.Err, color.noColor)
+	case a.Staple.Status == REVOCATION_REVOKED:
+		return fmt.Sprintf(" -> %sOCSP staple says REVOKED on %s (%s)%s\n", color.red, displayDate(a.Staple.RevokedAt), a.Staple.Reason, color.noColor)
+	case a.Staple.Status != REVOCATION_GOOD:
+		return fmt.Sprintf(" -> %sOCSP staple %s%s\n", color.yellow, a.Staple.Status, color.noColor)
+	case a.Staple.NextUpdate.IsZero():
+		return fmt.Sprintf(" -> %sOCSP staple good%s from %s\n", color.green, color.noColor, a.Staple.ThisUpdate.Format(dateLayout))
+	}
+	return fmt.Sprintf(" -> %sOCSP staple good%s from %s until %s\n", color.green, color.noColor, a.Staple.ThisUpdate.Format(dateLayout), displayDate(a.Staple.NextUpdate))
+}
+
+// stapleCsv is the staple column of CSV output.
+func (a CheckedServer) stapleCsv() string {
+	switch {
+	case a.TlsVersion == 0:
+		return ""
+	case a.Staple == nil:
+		return "none"
+	case a.Staple.Err != "":
+		return "invalid"
+	}
+	return a.Staple.Status
+}
@@ -0,0 +1,153 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestMustStapleLeaf(t *testing.T, ca *testCertificate) *testCertificate {
+	features, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "localhost"},
+		DNSNames:        []string{"localhost"},
+		IPAddresses:     []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{{Id: oidTlsFeature, Value: features}},
+	}, ca)
+}
+
+// testStaple serves leaf with staple attached to the handshake.
+func testStaple(t *testing.T, leaf *testCertificate, ca *testCertificate, staple []byte) CheckedServer {
+	certificate := leaf.tlsCertificate(ca)
+	certificate.OCSPStaple = staple
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{certificate}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	return checker.CheckServer("tls://"+address, true)
+}
+
+func Test_CheckServer_stapleGood(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	nextUpdate := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	actual := testStaple(t, leaf, ca, newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{Good: true, NextUpdate: nextUpdate}))
+
+	if !actual.Passed || !actual.Stapled || actual.MustStaple || actual.Staple.Status != REVOCATION_GOOD {
+		t.Fatal("expected a good staple to pass", actual.Err, actual.Staple)
+	}
+	if !actual.Staple.NextUpdate.Equal(nextUpdate) {
+		t.Error("expected the staple validity window, got", actual.Staple.NextUpdate)
+	}
+	if !strings.Contains(actual.AsString(false), " -> OCSP staple good from ") {
+		t.Error("expected the staple in text output", actual.AsString(false))
+	}
+	assert(t, strings.Split(actual.AsCsv(), ",")[6], "good", "")
+	if !strings.Contains(actual.AsJson(), `"Stapled":true`) {
+		t.Error("expected the staple in JSON output", actual.AsJson())
+	}
+}
+
+func Test_CheckServer_stapleMissing(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	actual := testStaple(t, newTestLeaf(t, ca, "localhost"), ca, nil)
+
+	if !actual.Passed || actual.Stapled || actual.Staple != nil {
+		t.Error("a missing staple should only fail Must-Staple certificates", actual.Err)
+	}
+	assert(t, strings.Split(actual.AsCsv(), ",")[6], "none", "")
+}
+
+func Test_CheckServer_mustStapleWithoutStaple(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	actual := testStaple(t, newTestMustStapleLeaf(t, ca), ca, nil)
+
+	if actual.Passed || !actual.MustStaple || actual.ExitCode != RETURNCODE_ERROR {
+		t.Fatal("expected a Must-Staple certificate without a staple to fail")
+	}
+	if !strings.Contains(actual.AsString(false), "no OCSP staple, but the certificate is Must-Staple") {
+		t.Error("expected the missing staple in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_mustStapleKeepsExitCode(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	features, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := newTestCertificate(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "localhost"},
+		DNSNames:        []string{"localhost"},
+		IPAddresses:     []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{{Id: oidTlsFeature, Value: features}},
+		NotBefore:       time.Now().Add(-48 * time.Hour),
+		NotAfter:        time.Now().Add(-24 * time.Hour),
+	}, ca)
+	actual := testStaple(t, expired, ca, nil)
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_EXPIRED {
+		t.Error("expected an expired certificate to keep its return code", actual.ExitCode)
+	}
+}
+
+func Test_CheckServer_mustStapleWithStaple(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestMustStapleLeaf(t, ca)
+	actual := testStaple(t, leaf, ca, newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{Good: true}))
+
+	if !actual.Passed || !actual.MustStaple {
+		t.Error("expected a Must-Staple certificate with a good staple to pass", actual.Err)
+	}
+}
+
+func Test_CheckServer_stapleStale(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	actual := testStaple(t, leaf, ca, newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{
+		Good:       true,
+		ThisUpdate: time.Now().Add(-72 * time.Hour).UTC().Truncate(time.Second),
+		NextUpdate: time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+	}))
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_ERROR || !strings.Contains(actual.Err, "stale") {
+		t.Error("expected a stale staple to fail", actual.Err)
+	}
+	assert(t, strings.Split(actual.AsCsv(), ",")[6], "invalid", "")
+}
+
+func Test_CheckServer_stapleRevoked(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	actual := testStaple(t, leaf, ca, newOcspResponse(t, leaf.Cert, ca, ca, ocspSingleResponse{
+		Revoked: ocspRevokedInfo{RevocationTime: time.Now().Add(-time.Hour).UTC().Truncate(time.Second)},
+	}))
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_REVOKED {
+		t.Error("expected a revoked staple to fail with RETURNCODE_REVOKED", actual.ExitCode)
+	}
+	if !strings.Contains(actual.AsString(false), "OCSP staple says REVOKED on") {
+		t.Error("expected the revocation in text output", actual.AsString(false))
+	}
+}
+
+func Test_CheckServer_stapleBadSignature(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	other := newTestCA(t, "Other CA")
+	actual := testStaple(t, leaf, ca, newOcspResponse(t, leaf.Cert, ca, other, ocspSingleResponse{Good: true}))
+
+	if actual.Passed || !strings.Contains(actual.Err, "signature is invalid") {
+		t.Error("expected a staple that was not signed by the issuer to fail", actual.Err)
+	}
+}
@@ -0,0 +1,290 @@
+package checkssl
+
+import (
+	"encoding/asn1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	starttlsClientName = "checkssl"
+
+	ldapStartTlsOid          = "1.3.6.1.4.1.1466.20037"
+	ldapExtendedResponseTag  = 24
+	ldapResultSuccess        = 0
+	xmppStreamNamespace      = "http://etherx.jabber.org/streams"
+	xmppStartTlsNamespace    = "urn:ietf:params:xml:ns:xmpp-tls"
+	berLongFormLengthFlag    = 0x80
+	berMaxLengthOctets       = 4
+	ldapMaxResponseBodyBytes = 1 << 16
+)
+
+// startTlsSmtp handles both smtp (25) and submission (587), see RFC 3207.
+func startTlsSmtp(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+
+	_, _, err := text.ReadResponse(220)
+	if err != nil {
+		return fmt.Errorf("smtp greeting failed: %w", err)
+	}
+
+	_, extensions, err := smtpCommand(text, 250, "EHLO %s", starttlsClientName)
+	if err != nil {
+		return fmt.Errorf("smtp EHLO failed: %w", err)
+	}
+	if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+		return fmt.Errorf("smtp server does not offer STARTTLS")
+	}
+
+	_, _, err = smtpCommand(text, 220, "STARTTLS")
+	if err != nil {
+		return fmt.Errorf("smtp STARTTLS failed: %w", err)
+	}
+	return nil
+}
+
+func smtpCommand(text *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	return text.ReadResponse(expectCode)
+}
+
+// startTlsImap sends the STARTTLS command from RFC 3501 once the server greets us.
+func startTlsImap(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return fmt.Errorf("imap greeting failed: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("imap greeting failed: %s", greeting)
+	}
+
+	err = text.PrintfLine("a1 STARTTLS")
+	if err != nil {
+		return err
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return fmt.Errorf("imap STARTTLS failed: %w", err)
+		}
+		if strings.HasPrefix(line, "* ") {
+			continue // untagged responses can arrive before our tagged reply
+		}
+		if strings.HasPrefix(line, "a1 OK") {
+			return nil
+		}
+		return fmt.Errorf("imap STARTTLS failed: %s", line)
+	}
+}
+
+// startTlsPop3 sends the STLS command from RFC 2595.
+func startTlsPop3(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return fmt.Errorf("pop3 greeting failed: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("pop3 greeting failed: %s", greeting)
+	}
+
+	err = text.PrintfLine("STLS")
+	if err != nil {
+		return err
+	}
+	reply, err := text.ReadLine()
+	if err != nil {
+		return fmt.Errorf("pop3 STLS failed: %w", err)
+	}
+	if !strings.HasPrefix(reply, "+OK") {
+		return fmt.Errorf("pop3 STLS failed: %s", reply)
+	}
+	return nil
+}
+
+// startTlsFtp sends AUTH TLS from RFC 4217.
+func startTlsFtp(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+
+	_, _, err := text.ReadResponse(220)
+	if err != nil {
+		return fmt.Errorf("ftp greeting failed: %w", err)
+	}
+
+	_, _, err = smtpCommand(text, 234, "AUTH TLS")
+	if err != nil {
+		return fmt.Errorf("ftp AUTH TLS failed: %w", err)
+	}
+	return nil
+}
+
+type ldapExtendedRequest struct {
+	Name []byte `asn1:"tag:0"`
+}
+
+type ldapMessage struct {
+	MessageID int
+	Request   ldapExtendedRequest `asn1:"application,tag:23"`
+}
+
+// startTlsLdap sends the StartTLS extended operation from RFC 4511 and waits
+// for a successful extended response.
+func startTlsLdap(conn net.Conn, serverName string) error {
+	request, err := asn1.Marshal(ldapMessage{
+		MessageID: 1,
+		Request:   ldapExtendedRequest{Name: []byte(ldapStartTlsOid)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(request)
+	if err != nil {
+		return err
+	}
+
+	message, err := readBerElement(conn)
+	if err != nil {
+		return fmt.Errorf("ldap StartTLS failed: %w", err)
+	}
+
+	var response struct {
+		MessageID int
+		Response  asn1.RawValue
+	}
+	_, err = asn1.Unmarshal(message, &response)
+	if err != nil {
+		return fmt.Errorf("ldap StartTLS failed: %w", err)
+	}
+	if response.Response.Class != asn1.ClassApplication || response.Response.Tag != ldapExtendedResponseTag {
+		return fmt.Errorf("ldap StartTLS failed: unexpected response tag %d", response.Response.Tag)
+	}
+
+	var resultCode asn1.Enumerated
+	rest, err := asn1.Unmarshal(response.Response.Bytes, &resultCode)
+	if err != nil {
+		return fmt.Errorf("ldap StartTLS failed: %w", err)
+	}
+	if resultCode != ldapResultSuccess {
+		var matchedDn, diagnostic []byte
+		rest, _ = asn1.Unmarshal(rest, &matchedDn)
+		_, _ = asn1.Unmarshal(rest, &diagnostic)
+		return fmt.Errorf("ldap StartTLS failed: result code %d %s", resultCode, diagnostic)
+	}
+	return nil
+}
+
+// readBerElement reads one complete tag-length-value element off the wire.
+func readBerElement(conn io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if header[1]&berLongFormLengthFlag != 0 {
+		octets := int(header[1] &^ berLongFormLengthFlag)
+		if octets == 0 || octets > berMaxLengthOctets {
+			return nil, errors.New("unsupported BER length")
+		}
+		lengthBytes := make([]byte, octets)
+		_, err = io.ReadFull(conn, lengthBytes)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > ldapMaxResponseBodyBytes {
+		return nil, errors.New("response is too large")
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(conn, body)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, body...), nil
+}
+
+// startTlsXmpp opens a client stream to serverName and negotiates
+// <starttls/> as described in RFC 6120 section 5.
+func startTlsXmpp(conn net.Conn, serverName string) error {
+	_, err := fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' xmlns:stream='%s' version='1.0'>", xmlEscape(serverName), xmppStreamNamespace)
+	if err != nil {
+		return err
+	}
+
+	decoder := xml.NewDecoder(conn)
+	offered, err := readXmppFeatures(decoder)
+	if err != nil {
+		return fmt.Errorf("xmpp stream failed: %w", err)
+	}
+	if !offered {
+		return errors.New("xmpp server does not offer STARTTLS")
+	}
+
+	_, err = fmt.Fprintf(conn, "<starttls xmlns='%s'/>", xmppStartTlsNamespace)
+	if err != nil {
+		return err
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("xmpp STARTTLS failed: %w", err)
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Space != xmppStartTlsNamespace {
+			continue
+		}
+		switch element.Name.Local {
+		case "proceed":
+			return nil
+		case "failure":
+			return errors.New("xmpp STARTTLS failed: server sent <failure/>")
+		}
+	}
+}
+
+// readXmppFeatures reads the stream header up to the end of <stream:features>
+// and reports whether <starttls/> was one of them.
+func readXmppFeatures(decoder *xml.Decoder) (offered bool, err error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return offered, err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if element.Name.Space == xmppStartTlsNamespace && element.Name.Local == "starttls" {
+				offered = true
+			}
+		case xml.EndElement:
+			if element.Name.Space == xmppStreamNamespace && element.Name.Local == "features" {
+				return offered, nil
+			}
+		}
+	}
+}
+
+func xmlEscape(input string) string {
+	var output strings.Builder
+	_ = xml.EscapeText(&output, []byte(input))
+	return output.String()
+}
@@ -0,0 +1,181 @@
+package checkssl
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeStartTlsServer plays a scripted plaintext conversation, replying to each
+// line the client sends in order, then switches the connection over to TLS.
+func fakeStartTlsServer(t *testing.T, greeting string, replies ...string) string {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	handshake := serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}})
+
+	return startTestServer(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		_, _ = conn.Write([]byte(greeting))
+		for _, reply := range replies {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(reply))
+		}
+		handshake(conn)
+	})
+}
+
+// fakePreambleServer runs preamble on each connection and, if it returns true,
+// continues with a TLS handshake.
+func fakePreambleServer(t *testing.T, preamble func(conn net.Conn) bool) string {
+	leaf := newTestLeaf(t, newTestCA(t, "Test CA"), "localhost")
+	handshake := serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate()}})
+
+	return startTestServer(t, func(conn net.Conn) {
+		if preamble(conn) {
+			handshake(conn)
+		}
+	})
+}
+
+func testStartTls(t *testing.T, target string) CheckedServer {
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer(target, false)
+
+	if len(actual.Certs) != 1 || actual.Certs[0].CommonName != "localhost" {
+		t.Fatal("expected the certificate after STARTTLS, got", actual.Certs, actual.Err)
+	}
+	if actual.TlsVersion == 0 {
+		t.Error("expected the negotiated TLS version to be recorded")
+	}
+	return actual
+}
+
+func Test_CheckServer_smtp(t *testing.T) {
+	address := fakeStartTlsServer(t,
+		"220-mail.example.com ESMTP\r\n220 ready\r\n",
+		"250-mail.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n",
+		"220 2.0.0 Ready to start TLS\r\n")
+	actual := testStartTls(t, "smtp://"+address)
+	assert(t, actual.Target, "smtp://"+address, "")
+}
+
+func Test_CheckServer_submission(t *testing.T) {
+	address := fakeStartTlsServer(t,
+		"220 mail.example.com ESMTP\r\n",
+		"250-mail.example.com\r\n250 STARTTLS\r\n",
+		"220 Ready to start TLS\r\n")
+	testStartTls(t, "submission://"+address)
+}
+
+func Test_CheckServer_smtpWithoutStartTls(t *testing.T) {
+	address := fakeStartTlsServer(t,
+		"220 mail.example.com ESMTP\r\n",
+		"250-mail.example.com\r\n250 PIPELINING\r\n")
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("smtp://"+address, false)
+	if actual.Passed || !strings.Contains(actual.Err, "does not offer STARTTLS") {
+		t.Error("expected a server without STARTTLS to fail, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_imap(t *testing.T) {
+	address := fakeStartTlsServer(t,
+		"* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n",
+		"* CAPABILITY IMAP4rev1 STARTTLS\r\na1 OK Begin TLS negotiation now\r\n")
+	testStartTls(t, "imap://"+address)
+}
+
+func Test_CheckServer_pop3(t *testing.T) {
+	address := fakeStartTlsServer(t,
+		"+OK POP3 ready\r\n",
+		"+OK Begin TLS negotiation\r\n")
+	testStartTls(t, "pop3://"+address)
+}
+
+func Test_CheckServer_ftp(t *testing.T) {
+	address := fakeStartTlsServer(t,
+		"220-Welcome\r\n220 FTP ready\r\n",
+		"234 AUTH TLS successful\r\n")
+	testStartTls(t, "ftp://"+address)
+}
+
+func fakeLdap(resultCode byte) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		request, err := readBerElement(conn)
+		if err != nil || !bytes.Contains(request, []byte(ldapStartTlsOid)) {
+			return false
+		}
+		// messageID 1, ExtendedResponse { resultCode, matchedDN "", diagnosticMessage "nope" }
+		_, _ = conn.Write([]byte{0x30, 0x10, 0x02, 0x01, 0x01, 0x78, 0x0b, 0x0a, 0x01, resultCode, 0x04, 0x00, 0x04, 0x04, 'n', 'o', 'p', 'e'})
+		return resultCode == ldapResultSuccess
+	}
+}
+
+func Test_CheckServer_ldap(t *testing.T) {
+	address := fakePreambleServer(t, fakeLdap(ldapResultSuccess))
+	testStartTls(t, "ldap://"+address)
+}
+
+func Test_CheckServer_ldapRefused(t *testing.T) {
+	address := fakePreambleServer(t, fakeLdap(2))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("ldap://"+address, false)
+	assert(t, actual.Err, "ldap StartTLS failed: result code 2 nope", "")
+}
+
+func fakeXmpp(streamHeaders chan string, features string) func(conn net.Conn) bool {
+	return func(conn net.Conn) bool {
+		header := make([]byte, 1024)
+		count, err := conn.Read(header)
+		if err != nil {
+			return false
+		}
+		streamHeaders <- string(header[:count])
+
+		_, _ = io.WriteString(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0' from='example.com' id='1'>")
+		_, _ = io.WriteString(conn, "<stream:features>"+features+"<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>PLAIN</mechanism></mechanisms></stream:features>")
+
+		request := make([]byte, 1024)
+		count, err = conn.Read(request)
+		if err != nil || !strings.Contains(string(request[:count]), "<starttls") {
+			return false
+		}
+		_, _ = io.WriteString(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+		return true
+	}
+}
+
+func Test_CheckServer_xmppDomain(t *testing.T) {
+	streamHeaders := make(chan string, 4)
+	address := fakePreambleServer(t, fakeXmpp(streamHeaders, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls>"))
+
+	actual := testStartTls(t, "xmpp://"+address+"?domain=example.com")
+	assert(t, actual.Target, "xmpp://"+address+"?domain=example.com", "")
+
+	header := <-streamHeaders
+	if !strings.Contains(header, "to='example.com'") {
+		t.Error("expected the stream to be addressed to the domain, got", header)
+	}
+	if !strings.Contains(actual.Err, "example.com") {
+		t.Error("expected the certificate to be verified against the domain, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_xmppWithoutStartTls(t *testing.T) {
+	address := fakePreambleServer(t, fakeXmpp(make(chan string, 4), ""))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("xmpp://"+address, false)
+	assert(t, actual.Err, "xmpp server does not offer STARTTLS", "")
+}
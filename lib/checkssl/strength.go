@@ -0,0 +1,85 @@
+package checkssl
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+const (
+	STRENGTH_FAIL    = "fail"
+	STRENGTH_WARNING = "warning"
+
+	DEFAULT_MIN_RSA_BITS = 2048
+	DEFAULT_MIN_EC_BITS  = 256
+)
+
+var weakSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// SetMinRsaBits is the smallest RSA key allowed in any certificate, 2048 by default.
+func (a *CheckSSL) SetMinRsaBits(bits int) {
+	a.minRsaBits = bits
+}
+
+// SetMinEcBits is the smallest EC curve allowed in any certificate, 256 by
+// default so P-224 and smaller curves fail.
+func (a *CheckSSL) SetMinEcBits(bits int) {
+	a.minEcBits = bits
+}
+
+// checkStrength marks certificates with keys below the minimums or signed
+// with MD5 or SHA-1. Clients never check the signature on a self signed
+// root, so a weak one there is only a warning.
+func (a *CheckSSL) checkStrength(certInfo *CheckCert, cert *x509.Certificate) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < a.minRsaBits {
+			certInfo.addStrengthIssue(STRENGTH_FAIL, fmt.Sprintf("RSA %d bit key is below the %d bit minimum", key.N.BitLen(), a.minRsaBits))
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().BitSize < a.minEcBits {
+			certInfo.addStrengthIssue(STRENGTH_FAIL, fmt.Sprintf("ECDSA %s key is below the %d bit minimum", key.Curve.Params().Name, a.minEcBits))
+		}
+	case *dsa.PublicKey:
+		certInfo.addStrengthIssue(STRENGTH_FAIL, "DSA keys are no longer accepted by clients")
+	}
+
+	if weakSignatureAlgorithms[cert.SignatureAlgorithm] {
+		if isSelfSigned(cert) {
+			certInfo.addStrengthIssue(STRENGTH_WARNING, fmt.Sprintf("self signed with %s, which clients do not check on a root", cert.SignatureAlgorithm))
+		} else {
+			certInfo.addStrengthIssue(STRENGTH_FAIL, fmt.Sprintf("signed with %s, which is no longer secure", cert.SignatureAlgorithm))
+		}
+	}
+}
+
+// addStrengthIssue keeps the worst severity in Strength.
+func (a *CheckCert) addStrengthIssue(severity string, issue string) {
+	a.StrengthIssues = append(a.StrengthIssues, issue)
+	if a.Strength != STRENGTH_FAIL {
+		a.Strength = severity
+	}
+}
+
+func (a *CheckCert) strengthFindings(color terminalColors) string {
+	if a.Strength == "" {
+		return ""
+	}
+	severityColor := color.yellow
+	if a.Strength == STRENGTH_FAIL {
+		severityColor = color.red
+	}
+	output := ""
+	for _, issue := range a.StrengthIssues {
+		output += fmt.Sprintf("      %sweak %s%s\n", severityColor, issue, color.noColor)
+	}
+	return output
+}
@@ -0,0 +1,116 @@
+package checkssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRsaLeaf(t *testing.T, ca *testCertificate, bits int) *testCertificate {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{Cert: cert, Key: key}
+}
+
+func Test_checkStrength_rsa(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	checker := NewCheckSSL()
+
+	actual := CheckCert{}
+	checker.checkStrength(&actual, newTestRsaLeaf(t, ca, 1024).Cert)
+	assert(t, actual.Strength, STRENGTH_FAIL, "")
+	assert(t, strings.Join(actual.StrengthIssues, ", "), "RSA 1024 bit key is below the 2048 bit minimum", "")
+
+	strong := CheckCert{}
+	checker.checkStrength(&strong, newTestRsaLeaf(t, ca, 2048).Cert)
+	assert(t, strong.Strength, "", "a 2048 bit key should pass by default")
+
+	checker.SetMinRsaBits(3072)
+	stricter := CheckCert{}
+	checker.checkStrength(&stricter, newTestRsaLeaf(t, ca, 2048).Cert)
+	assert(t, strings.Join(stricter.StrengthIssues, ", "), "RSA 2048 bit key is below the 3072 bit minimum", "")
+}
+
+func Test_checkStrength_ec(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewCheckSSL()
+
+	actual := CheckCert{}
+	checker.checkStrength(&actual, &x509.Certificate{PublicKey: &key.PublicKey, SignatureAlgorithm: x509.ECDSAWithSHA256})
+	assert(t, strings.Join(actual.StrengthIssues, ", "), "ECDSA P-224 key is below the 256 bit minimum", "")
+
+	checker.SetMinEcBits(384)
+	stricter := CheckCert{}
+	checker.checkStrength(&stricter, newTestCA(t, "Test CA").Cert)
+	assert(t, strings.Join(stricter.StrengthIssues, ", "), "ECDSA P-256 key is below the 384 bit minimum", "")
+}
+
+func Test_checkStrength_signature(t *testing.T) {
+	checker := NewCheckSSL()
+	key := newTestCA(t, "Test CA").Cert.PublicKey
+
+	actual := CheckCert{}
+	checker.checkStrength(&actual, &x509.Certificate{PublicKey: key, SignatureAlgorithm: x509.SHA1WithRSA})
+	assert(t, actual.Strength, STRENGTH_FAIL, "")
+	assert(t, strings.Join(actual.StrengthIssues, ", "), "signed with SHA1-RSA, which is no longer secure", "")
+
+	md5 := CheckCert{}
+	checker.checkStrength(&md5, &x509.Certificate{PublicKey: key, SignatureAlgorithm: x509.MD5WithRSA})
+	assert(t, strings.Join(md5.StrengthIssues, ", "), "signed with MD5-RSA, which is no longer secure", "")
+}
+
+func Test_CheckServer_weakKey(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestRsaLeaf(t, ca, 1024)
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate(ca)}}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if actual.Passed || actual.ExitCode != RETURNCODE_WEAKKEY {
+		t.Fatal("expected a 1024 bit key to fail with RETURNCODE_WEAKKEY, got", actual.ExitCode, actual.Err)
+	}
+	assert(t, actual.Certs[1].Strength, "", "the CA key is strong enough")
+	if !strings.Contains(actual.AsString(false), "\n      weak RSA 1024 bit key is below the 2048 bit minimum\n") {
+		t.Error("expected the weak key under the certificate in text output", actual.AsString(false))
+	}
+	if !strings.Contains(actual.AsJson(), `"Strength":"fail","StrengthIssues":["RSA 1024 bit key is below the 2048 bit minimum"]`) {
+		t.Error("expected the marker in JSON output", actual.AsJson())
+	}
+	assert(t, strings.Split(actual.AsCsv(), ",")[1], "WEAK", "")
+
+	checker.SetMinRsaBits(1024)
+	relaxed := checker.CheckServer("tls://"+address, true)
+	if !relaxed.Passed {
+		t.Error("expected a lower minimum to let the key pass", relaxed.ExitCode)
+	}
+}
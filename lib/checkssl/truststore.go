@@ -0,0 +1,144 @@
+package checkssl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const TRUST_STORE_SYSTEM = "system"
+
+// SetRootCAs verifies certificates against pool instead of the system roots.
+func (a *CheckSSL) SetRootCAs(pool *x509.CertPool) {
+	a.rootCAs = pool
+	a.caCerts = nil
+	a.caSources = []string{"custom"}
+}
+
+// AddCaFile loads the PEM certificates in path as trusted roots. They replace
+// the system roots unless SetAppendSystemRoots is turned on.
+func (a *CheckSSL) AddCaFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	certs, err := parsePemCertificates(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("%s: no PEM certificates found", path)
+	}
+	a.addCaCerts(path, certs)
+	return nil
+}
+
+// AddCaDir loads the PEM certificates from every file in dir, like the
+// folders given to openssl with -CApath. Files without certificates are
+// skipped.
+func (a *CheckSSL) AddCaDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path) // follows the hash symlinks made by c_rehash
+		if err != nil || info.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found, err := parsePemCertificates(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, found...)
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("%s: no PEM certificates found", dir)
+	}
+	a.addCaCerts(dir, certs)
+	return nil
+}
+
+// SetAppendSystemRoots keeps the system roots and adds the CA files to them,
+// instead of only trusting the CA files.
+func (a *CheckSSL) SetAppendSystemRoots(enable bool) {
+	a.appendSystemRoots = enable
+	if len(a.caCerts) > 0 {
+		a.rootCAs = a.buildRootCAs()
+	}
+}
+
+func (a *CheckSSL) addCaCerts(source string, certs []*x509.Certificate) {
+	if a.caCerts == nil {
+		a.caSources = nil // replaces a pool from SetRootCAs
+	}
+	a.caCerts = append(a.caCerts, certs...)
+	a.caSources = append(a.caSources, source)
+	a.rootCAs = a.buildRootCAs()
+}
+
+// buildRootCAs is done as each file is added, since the pool is shared by
+// every concurrent check.
+func (a *CheckSSL) buildRootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if a.appendSystemRoots {
+		system, err := x509.SystemCertPool()
+		if err == nil {
+			pool = system.Clone()
+		}
+	}
+	for _, cert := range a.caCerts {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// trustStore names the roots certificates are verified against.
+func (a *CheckSSL) trustStore() string {
+	if len(a.caSources) == 0 {
+		return TRUST_STORE_SYSTEM
+	}
+	sources := strings.Join(a.caSources, ", ")
+	if a.appendSystemRoots && len(a.caCerts) > 0 {
+		return TRUST_STORE_SYSTEM + " + " + sources
+	}
+	return sources
+}
+
+// trustError adds the trust store to an unknown authority error, so it is
+// clear which roots the chain was checked against.
+func (a *CheckSSL) trustError(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return fmt.Sprintf("%s (trust store: %s)", err, a.trustStore())
+	}
+	return err.Error()
+}
+
+func parsePemCertificates(data []byte) (output []*x509.Certificate, err error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return output, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, cert)
+	}
+}
@@ -0,0 +1,148 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestCaFile(t *testing.T, dir string, name string, certs ...*testCertificate) string {
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Cert.Raw})...)
+	}
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func startPrivateCaServer(t *testing.T) (*testCertificate, string) {
+	root := newTestCA(t, "Private Root")
+	intermediate := newTestIntermediate(t, root, "Private Intermediate")
+	leaf := newTestLeaf(t, intermediate, "localhost")
+	address := startTestServer(t, serveTls(&tls.Config{Certificates: []tls.Certificate{leaf.tlsCertificate(intermediate)}}))
+	return root, address
+}
+
+func Test_CheckServer_systemTrustStore(t *testing.T) {
+	_, address := startPrivateCaServer(t)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, false)
+
+	if actual.Passed || actual.TrustStore != "" {
+		t.Fatal("a private CA should not be trusted by the system roots")
+	}
+	if !strings.HasSuffix(actual.Err, "certificate signed by unknown authority (trust store: system)") {
+		t.Error("expected the error to name the trust store, got", actual.Err)
+	}
+}
+
+func Test_CheckServer_caFile(t *testing.T) {
+	root, address := startPrivateCaServer(t)
+	path := writeTestCaFile(t, t.TempDir(), "private.pem", root)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	err := checker.AddCaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := checker.CheckServer("tls://"+address, false)
+	if !actual.Passed {
+		t.Fatal("expected the private CA to be trusted", actual.Err)
+	}
+	if !actual.Chain.Complete || len(actual.Chain.Findings) != 0 {
+		t.Error("expected the chain to end at a trusted root", actual.Chain.Findings)
+	}
+	assert(t, actual.TrustStore, path, "")
+	if !strings.Contains(actual.AsJson(), `"TrustStore":"`+path+`"`) {
+		t.Error("expected the trust store in JSON output", actual.AsJson())
+	}
+}
+
+func Test_AddCaFile_appendSystemRoots(t *testing.T) {
+	root, address := startPrivateCaServer(t)
+	path := writeTestCaFile(t, t.TempDir(), "private.pem", root)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetAppendSystemRoots(true)
+	err := checker.AddCaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := checker.CheckServer("tls://"+address, false)
+	if !actual.Passed {
+		t.Fatal("expected the private CA to be trusted", actual.Err)
+	}
+	assert(t, actual.TrustStore, "system + "+path, "")
+}
+
+func Test_AddCaDir(t *testing.T) {
+	root, address := startPrivateCaServer(t)
+	dir := t.TempDir()
+	writeTestCaFile(t, dir, "other.pem", newTestCA(t, "Other Root"))
+	writeTestCaFile(t, dir, "private.crt", root)
+	err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	err = checker.AddCaDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := checker.CheckServer("tls://"+address, false)
+	if !actual.Passed {
+		t.Fatal("expected the private CA to be trusted", actual.Err)
+	}
+	assert(t, actual.TrustStore, dir, "")
+
+	err = checker.AddCaDir(t.TempDir())
+	if err == nil || !strings.HasSuffix(err.Error(), "no PEM certificates found") {
+		t.Error("expected an empty folder to be an error, got", err)
+	}
+}
+
+func Test_AddCaFile_invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pem")
+	err := os.WriteFile(path, []byte("nothing here"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewCheckSSL()
+	err = checker.AddCaFile(path)
+	assert(t, err.Error(), path+": no PEM certificates found", "")
+	assert(t, checker.trustStore(), TRUST_STORE_SYSTEM, "a failed load should not change the trust store")
+}
+
+func Test_SetRootCAs(t *testing.T) {
+	root, address := startPrivateCaServer(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(root.Cert)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetRootCAs(pool)
+
+	actual := checker.CheckServer("tls://"+address, false)
+	if !actual.Passed {
+		t.Fatal("expected the pool to be trusted", actual.Err)
+	}
+	assert(t, actual.TrustStore, "custom", "")
+}
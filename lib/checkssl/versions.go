@@ -0,0 +1,96 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+const DEFAULT_MIN_TLS_VERSION = tls.VersionTLS12
+
+// probedTlsVersions are tried one at a time, SSL v3.0 is left out since Go
+// cannot make an SSL v3.0 handshake.
+var probedTlsVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// TlsVersionSupport is the result of a handshake pinned to one TLS version.
+type TlsVersionSupport struct {
+	Version  uint16
+	Name     string
+	Accepted bool
+	Err      string `json:",omitempty"`
+}
+
+// SetProbeTlsVersions makes a handshake at every TLS version to list which
+// ones the server accepts, not just the one that was negotiated.
+func (a *CheckSSL) SetProbeTlsVersions(enable bool) {
+	a.probeTlsVersions = enable
+}
+
+// SetMinTlsVersion fails the check when probing finds the server accepts a
+// version below this one, TLS v1.2 by default.
+func (a *CheckSSL) SetMinTlsVersion(version uint16) {
+	a.minTlsVersion = version
+}
+
+// ParseTlsVersion turns "1.0" through "1.3" into the tls package constant.
+func ParseTlsVersion(input string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(input), "tls") {
+	case "1.0", "1":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %s, expected 1.0, 1.1, 1.2 or 1.3", input)
+}
+
+// checkTlsVersions pins MinVersion and MaxVersion to each version in turn and
+// offers every cipher suite for it. Certificates are not verified here since
+// the normal check already has.
+func (a *CheckSSL) checkTlsVersions(output *CheckedServer, serverName string, address string, upgrade tlsUpgrade) {
+	output.TlsVersions = nil
+	for _, version := range probedTlsVersions {
+		support := TlsVersionSupport{Version: version, Name: tls.VersionName(version)}
+		_, err := a.probeHandshake(serverName, address, upgrade, version, probedCipherSuites(version))
+		if err == nil {
+			support.Accepted = true
+		} else {
+			support.Err = err.Error()
+		}
+		output.TlsVersions = append(output.TlsVersions, support)
+	}
+
+	for _, support := range output.TlsVersions {
+		if support.Accepted && a.failBelowMinTlsVersion(output, support.Version) {
+			return
+		}
+	}
+}
+
+// failBelowMinTlsVersion fails the check when the server accepted a version
+// below the minimum.
+func (a *CheckSSL) failBelowMinTlsVersion(output *CheckedServer, version uint16) bool {
+	if version >= a.minTlsVersion {
+		return false
+	}
+	if output.Err == "" {
+		output.Err = fmt.Sprintf("server accepts %s, which is below the minimum of %s", tls.VersionName(version), tls.VersionName(a.minTlsVersion))
+	}
+	if output.ExitCode == RETURNCODE_PASS {
+		output.ExitCode = RETURNCODE_ERROR
+	}
+	output.Passed = false
+	return true
+}
+
+func (a CheckedServer) tlsVersionLines(color terminalColors) (output string) {
+	for _, support := range a.TlsVersions {
+		if support.Accepted {
+			output += fmt.Sprintf(" -> accepts %s\n", getTlsVersion(support.Version, color))
+		}
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package checkssl
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func startVersionServer(t *testing.T, minVersion uint16) string {
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestLeaf(t, ca, "localhost")
+	return startTestServer(t, serveTls(&tls.Config{
+		Certificates: []tls.Certificate{leaf.tlsCertificate(ca)},
+		MinVersion:   minVersion,
+		MaxVersion:   tls.VersionTLS13,
+	}))
+}
+
+func acceptedVersions(actual CheckedServer) (output []string) {
+	for _, support := range actual.TlsVersions {
+		if support.Accepted {
+			output = append(output, support.Name)
+		}
+	}
+	return
+}
+
+func Test_CheckServer_probeTlsVersions(t *testing.T) {
+	address := startVersionServer(t, tls.VersionTLS12)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetProbeTlsVersions(true)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if !actual.Passed {
+		t.Fatal("expected TLS v1.2 and up to pass", actual.Err)
+	}
+	assert(t, strings.Join(acceptedVersions(actual), ", "), "TLS 1.2, TLS 1.3", "")
+	if len(actual.TlsVersions) != 4 || actual.TlsVersions[0].Err == "" {
+		t.Error("expected every version to be listed with the reason it was rejected", actual.TlsVersions)
+	}
+	text := actual.AsString(false)
+	if !strings.Contains(text, " -> accepts TLS v1.2 (released 2008) - Consider upgrading to TLS v1.3\n -> accepts TLS v1.3 (released 2018) - latest version\n") {
+		t.Error("expected the accepted versions in text output", text)
+	}
+	if strings.Contains(text, "accepts TLS v1.0") {
+		t.Error("did not expect rejected versions in text output", text)
+	}
+}
+
+func Test_CheckServer_probeTlsVersionsBelowMinimum(t *testing.T) {
+	address := startVersionServer(t, tls.VersionTLS10)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetProbeTlsVersions(true)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	assert(t, strings.Join(acceptedVersions(actual), ", "), "TLS 1.0, TLS 1.1, TLS 1.2, TLS 1.3", "")
+	if actual.Passed || actual.ExitCode != RETURNCODE_ERROR {
+		t.Fatal("expected TLS v1.0 to fail the default minimum")
+	}
+	assert(t, actual.Err, "server accepts TLS 1.0, which is below the minimum of TLS 1.2", "")
+
+	checker.SetMinTlsVersion(tls.VersionTLS10)
+	relaxed := checker.CheckServer("tls://"+address, true)
+	if !relaxed.Passed {
+		t.Error("expected a lower minimum to pass", relaxed.Err)
+	}
+}
+
+func Test_CheckServer_probeTlsVersionsRsaKeyExchange(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	address := startTestServer(t, serveTls(&tls.Config{
+		Certificates: []tls.Certificate{newTestRsaLeaf(t, ca, 2048).tlsCertificate(ca)},
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   tls.VersionTLS13,
+		// only for TLS v1.0 through v1.2, TLS v1.3 suites are not configurable
+		CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+	}))
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	checker.SetProbeTlsVersions(true)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	assert(t, strings.Join(acceptedVersions(actual), ", "), "TLS 1.0, TLS 1.1, TLS 1.2, TLS 1.3", "Go's default suites have no RSA key exchange")
+	if actual.Passed {
+		t.Error("expected TLS v1.0 to fail the default minimum")
+	}
+}
+
+func Test_CheckServer_noTlsVersionProbe(t *testing.T) {
+	address := startVersionServer(t, tls.VersionTLS10)
+
+	checker := NewCheckSSL()
+	checker.SetTimeout(2)
+	actual := checker.CheckServer("tls://"+address, true)
+
+	if !actual.Passed || actual.TlsVersions != nil {
+		t.Error("versions should only be probed when asked for", actual.Err, actual.TlsVersions)
+	}
+}
+
+func Test_ParseTlsVersion(t *testing.T) {
+	for input, expected := range map[string]uint16{"1.0": tls.VersionTLS10, "1.1": tls.VersionTLS11, "TLS1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13} {
+		actual, err := ParseTlsVersion(input)
+		if err != nil || actual != expected {
+			t.Error("ParseTlsVersion", input, actual, err)
+		}
+	}
+	_, err := ParseTlsVersion("3.0")
+	if err == nil {
+		t.Error("expected an unknown version to be an error")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -11,16 +12,51 @@ import (
 )
 
 const (
-	VERSION        = "0.6.0"
-	BUILD_DATE     = "2024-Aug-5"
-	FLAG_DAYS      = "-days="
-	FLAG_JSON      = "-json"
-	FLAG_CSV       = "-csv"
-	FLAG_NO_COLOR  = "-no-color"
-	FLAG_NO_OUTPUT = "-no-output"
-	FLAG_SHORT     = "-short"
-	FLAG_NO_HEADER = "-no-header"
-	FLAG_TIMEOUT   = "-timeout="
+	VERSION          = "0.6.0"
+	BUILD_DATE       = "2024-Aug-5"
+	FLAG_DAYS        = "-days="
+	FLAG_JSON        = "-json"
+	FLAG_CSV         = "-csv"
+	FLAG_NO_COLOR    = "-no-color"
+	FLAG_NO_OUTPUT   = "-no-output"
+	FLAG_SHORT       = "-short"
+	FLAG_VERBOSE     = "-verbose"
+	FLAG_NO_HEADER   = "-no-header"
+	FLAG_TIMEOUT     = "-timeout="
+	FLAG_RAW_TLS     = "-raw-tls"
+	FLAG_CONCURRENCY = "-concurrency="
+	FLAG_STREAM      = "-stream"
+	FLAG_ALL_IPS     = "-all-ips"
+	FLAG_RESOLVE     = "-resolve="
+	FLAG_CONNECT_TO  = "-connect-to="
+	FLAG_PROXY       = "-proxy="
+	FLAG_NO_PROXY    = "-no-proxy"
+	FLAG_REDIRECTS   = "-max-redirects="
+	FLAG_OCSP        = "-ocsp"
+	FLAG_OCSP_URL    = "-ocsp-responder="
+	FLAG_CRL         = "-crl"
+	FLAG_CRL_FILE    = "-crl-file="
+	FLAG_CRL_CACHE   = "-crl-cache="
+	FLAG_CT          = "-ct"
+	FLAG_CT_LOGS     = "-ct-logs="
+	FLAG_AIA         = "-aia"
+	FLAG_CA_FILE     = "-ca-file="
+	FLAG_CA_DIR      = "-ca-dir="
+	FLAG_CA_APPEND   = "-ca-append"
+	FLAG_CLIENT_CERT = "-client-cert="
+	FLAG_CLIENT_KEY  = "-client-key="
+	FLAG_CLIENT_PASS = "-client-password="
+	FLAG_MIN_RSA     = "-min-rsa="
+	FLAG_MIN_EC      = "-min-ec="
+	FLAG_TLS_VERSION = "-tls-versions"
+	FLAG_MIN_TLS     = "-min-tls="
+	FLAG_CIPHERS     = "-ciphers"
+	FLAG_LEGACY      = "-legacy"
+	FLAG_SIMULATE    = "-simulate"
+	FLAG_CLIENTS     = "-client-profiles="
+	FLAG_HSTS        = "-hsts"
+	FLAG_HSTS_AGE    = "-hsts-min-age="
+	FLAG_HSTS_LIST   = "-hsts-preload="
 )
 
 var (
@@ -30,6 +66,39 @@ var (
 	enableHeader        = true
 	timeoutSeconds      = checkssl.DEFAULT_TIMEOUT_SEC
 	outputFormat        = checkssl.TEXT
+	enableRawTls        = false
+	concurrency         = 1
+	streamResults       = false
+	checkAllIps         = false
+	connectTo           = map[string]string{}
+	proxyUrl            = ""
+	enableEnvProxy      = true
+	maxRedirects        = checkssl.DEFAULT_MAX_REDIRECTS
+	enableOcsp          = false
+	ocspResponder       = ""
+	enableCrl           = false
+	crlFiles            []string
+	crlCacheDir         = ""
+	enableCt            = false
+	ctLogList           = ""
+	fetchIntermediates  = false
+	caFiles             []string
+	caDirs              []string
+	appendSystemRoots   = false
+	clientCertFile      = ""
+	clientKeyFile       = ""
+	clientPassword      = ""
+	minRsaBits          = checkssl.DEFAULT_MIN_RSA_BITS
+	minEcBits           = checkssl.DEFAULT_MIN_EC_BITS
+	probeTlsVersions    = false
+	minTlsVersion       = uint16(checkssl.DEFAULT_MIN_TLS_VERSION)
+	probeCipherSuites   = false
+	probeLegacy         = false
+	simulateClients     = false
+	clientProfiles      = ""
+	enableHsts          = false
+	hstsMinAge          = -1
+	hstsPreloadList     = ""
 )
 
 func main() {
@@ -45,9 +114,91 @@ func main() {
 	a := checkssl.NewCheckSSL()
 	a.SetThreshold(dateThreshold)
 	a.SetTimeout(timeoutSeconds)
+	a.SetRawTls(enableRawTls)
+	a.SetCheckAllIps(checkAllIps)
+	for address, override := range connectTo {
+		a.SetConnectTo(address, override)
+	}
+	a.SetProxyFromEnvironment(enableEnvProxy)
+	a.SetMaxRedirects(maxRedirects)
+	a.SetOcsp(enableOcsp)
+	a.SetOcspResponder(ocspResponder)
+	a.SetCrl(enableCrl)
+	if crlCacheDir != "" {
+		a.SetCrlCacheDir(crlCacheDir)
+	}
+	a.SetCt(enableCt)
+	a.SetFetchMissingIntermediates(fetchIntermediates)
+	a.SetMinRsaBits(minRsaBits)
+	a.SetMinEcBits(minEcBits)
+	a.SetProbeTlsVersions(probeTlsVersions)
+	a.SetMinTlsVersion(minTlsVersion)
+	a.SetProbeCipherSuites(probeCipherSuites)
+	a.SetProbeLegacy(probeLegacy)
+	a.SetSimulateClients(simulateClients)
+	if clientProfiles != "" {
+		err := a.AddClientProfiles(clientProfiles)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	a.SetHsts(enableHsts)
+	if hstsMinAge >= 0 {
+		a.SetHstsMinAge(hstsMinAge)
+	}
+	if hstsPreloadList != "" {
+		err := a.SetHstsPreloadList(hstsPreloadList)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	if ctLogList != "" {
+		err := a.SetCtLogList(ctLogList)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	a.SetAppendSystemRoots(appendSystemRoots)
+	for _, path := range caFiles {
+		err := a.AddCaFile(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	for _, dir := range caDirs {
+		err := a.AddCaDir(dir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	if clientCertFile != "" {
+		err := a.LoadClientCertificate(clientCertFile, clientKeyFile, clientPassword)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	for _, path := range crlFiles {
+		err := a.AddCrlFile(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
+	if proxyUrl != "" {
+		err := a.SetProxy(proxyUrl)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(checkssl.RETURNCODE_ERROR)
+		}
+	}
 
-	for i := range arguments {
-		result := a.CheckServer(arguments[i], false)
+	for result := range a.CheckServers(arguments, concurrency, streamResults) {
 		returnCode += result.ExitCode
 		if outputFormat == checkssl.JSON {
 			fmt.Println(result.AsJson())
@@ -57,6 +208,8 @@ func main() {
 			fmt.Println(result.AsString(enableTerminalColor))
 		} else if outputFormat == checkssl.SHORT {
 			fmt.Print(result.AsShortString(enableTerminalColor))
+		} else if outputFormat == checkssl.VERBOSE {
+			fmt.Println(result.AsVerboseString(enableTerminalColor))
 		}
 	}
 	os.Exit(returnCode)
@@ -92,6 +245,9 @@ func separateCommandLineArgumentsFromFlags() []string {
 			if strings.HasPrefix(value, FLAG_SHORT) {
 				outputFormat = checkssl.SHORT
 			}
+			if strings.HasPrefix(value, FLAG_VERBOSE) {
+				outputFormat = checkssl.VERBOSE
+			}
 			if strings.HasPrefix(value, FLAG_NO_OUTPUT) {
 				outputFormat = checkssl.NONE
 			}
@@ -106,6 +262,139 @@ func separateCommandLineArgumentsFromFlags() []string {
 				seconds, _ := strconv.ParseInt(parsableTimeout, 10, 32)
 				timeoutSeconds = int(seconds)
 			}
+			if strings.HasPrefix(value, FLAG_RAW_TLS) {
+				enableRawTls = true
+			}
+			if strings.HasPrefix(value, FLAG_CONCURRENCY) {
+				parsableConcurrency := strings.Replace(value, FLAG_CONCURRENCY, "", 1)
+				parsedConcurrency, _ := strconv.ParseInt(parsableConcurrency, 10, 32)
+				concurrency = int(parsedConcurrency)
+			}
+			if strings.HasPrefix(value, FLAG_STREAM) {
+				streamResults = true
+			}
+			if strings.HasPrefix(value, FLAG_ALL_IPS) {
+				checkAllIps = true
+			}
+			if strings.HasPrefix(value, FLAG_RESOLVE) {
+				// host:port:ip, the same as curl --resolve
+				parts := strings.SplitN(strings.Replace(value, FLAG_RESOLVE, "", 1), ":", 3)
+				if len(parts) == 3 {
+					connectTo[net.JoinHostPort(parts[0], parts[1])] = net.JoinHostPort(strings.Trim(parts[2], "[]"), parts[1])
+				}
+			}
+			if strings.HasPrefix(value, FLAG_CONNECT_TO) {
+				// host:port:connect-host:connect-port, the same as curl --connect-to
+				parts := strings.SplitN(strings.Replace(value, FLAG_CONNECT_TO, "", 1), ":", 3)
+				if len(parts) == 3 {
+					connectTo[net.JoinHostPort(parts[0], parts[1])] = parts[2]
+				}
+			}
+			if strings.HasPrefix(value, FLAG_PROXY) {
+				proxyUrl = strings.Replace(value, FLAG_PROXY, "", 1)
+			}
+			if strings.HasPrefix(value, FLAG_NO_PROXY) {
+				enableEnvProxy = false
+			}
+			if strings.HasPrefix(value, FLAG_REDIRECTS) {
+				parsableRedirects := strings.Replace(value, FLAG_REDIRECTS, "", 1)
+				parsedRedirects, _ := strconv.ParseInt(parsableRedirects, 10, 32)
+				maxRedirects = int(parsedRedirects)
+			}
+			if value == FLAG_OCSP {
+				enableOcsp = true
+			}
+			if strings.HasPrefix(value, FLAG_OCSP_URL) {
+				ocspResponder = strings.Replace(value, FLAG_OCSP_URL, "", 1)
+				enableOcsp = true
+			}
+			if value == FLAG_CRL {
+				enableCrl = true
+			}
+			if strings.HasPrefix(value, FLAG_CRL_FILE) {
+				crlFiles = append(crlFiles, strings.Replace(value, FLAG_CRL_FILE, "", 1))
+				enableCrl = true
+			}
+			if strings.HasPrefix(value, FLAG_CRL_CACHE) {
+				crlCacheDir = strings.Replace(value, FLAG_CRL_CACHE, "", 1)
+			}
+			if value == FLAG_CT {
+				enableCt = true
+			}
+			if strings.HasPrefix(value, FLAG_CT_LOGS) {
+				ctLogList = strings.Replace(value, FLAG_CT_LOGS, "", 1)
+				enableCt = true
+			}
+			if value == FLAG_AIA {
+				fetchIntermediates = true
+			}
+			if strings.HasPrefix(value, FLAG_CA_FILE) {
+				caFiles = append(caFiles, strings.Replace(value, FLAG_CA_FILE, "", 1))
+			}
+			if strings.HasPrefix(value, FLAG_CA_DIR) {
+				caDirs = append(caDirs, strings.Replace(value, FLAG_CA_DIR, "", 1))
+			}
+			if value == FLAG_CA_APPEND {
+				appendSystemRoots = true
+			}
+			if strings.HasPrefix(value, FLAG_CLIENT_CERT) {
+				clientCertFile = strings.Replace(value, FLAG_CLIENT_CERT, "", 1)
+			}
+			if strings.HasPrefix(value, FLAG_CLIENT_KEY) {
+				clientKeyFile = strings.Replace(value, FLAG_CLIENT_KEY, "", 1)
+			}
+			if strings.HasPrefix(value, FLAG_CLIENT_PASS) {
+				clientPassword = strings.Replace(value, FLAG_CLIENT_PASS, "", 1)
+			}
+			if strings.HasPrefix(value, FLAG_MIN_RSA) {
+				parsableBits := strings.Replace(value, FLAG_MIN_RSA, "", 1)
+				parsedBits, _ := strconv.ParseInt(parsableBits, 10, 32)
+				minRsaBits = int(parsedBits)
+			}
+			if strings.HasPrefix(value, FLAG_MIN_EC) {
+				parsableBits := strings.Replace(value, FLAG_MIN_EC, "", 1)
+				parsedBits, _ := strconv.ParseInt(parsableBits, 10, 32)
+				minEcBits = int(parsedBits)
+			}
+			if value == FLAG_TLS_VERSION {
+				probeTlsVersions = true
+			}
+			if strings.HasPrefix(value, FLAG_MIN_TLS) {
+				version, err := checkssl.ParseTlsVersion(strings.Replace(value, FLAG_MIN_TLS, "", 1))
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(checkssl.RETURNCODE_ERROR)
+				}
+				minTlsVersion = version
+				probeTlsVersions = true
+			}
+			if value == FLAG_CIPHERS {
+				probeCipherSuites = true
+				probeTlsVersions = true
+			}
+			if value == FLAG_LEGACY {
+				probeLegacy = true
+			}
+			if value == FLAG_SIMULATE {
+				simulateClients = true
+			}
+			if strings.HasPrefix(value, FLAG_CLIENTS) {
+				clientProfiles = strings.Replace(value, FLAG_CLIENTS, "", 1)
+				simulateClients = true
+			}
+			if value == FLAG_HSTS {
+				enableHsts = true
+			}
+			if strings.HasPrefix(value, FLAG_HSTS_AGE) {
+				parsableAge := strings.Replace(value, FLAG_HSTS_AGE, "", 1)
+				parsedAge, _ := strconv.ParseInt(parsableAge, 10, 32)
+				hstsMinAge = int(parsedAge)
+				enableHsts = true
+			}
+			if strings.HasPrefix(value, FLAG_HSTS_LIST) {
+				hstsPreloadList = strings.Replace(value, FLAG_HSTS_LIST, "", 1)
+				enableHsts = true
+			}
 			continue
 			// this allows flags to be mixed into the arguments
 		}
@@ -130,5 +419,40 @@ func displayHelpText(errorText string) {
 	fmt.Println("  -no-output (will only produce exit code)")
 	fmt.Println("  -no-header (will disable the header row in CSV output)")
 	fmt.Println("  -short (will show only 1 line per result)")
+	fmt.Println("  -verbose (will show every detail of each certificate)")
 	fmt.Println("  -timeout=5 (will set the timeout to 5 seconds)", " default =", checkssl.DEFAULT_TIMEOUT_SEC)
+	fmt.Println("  -raw-tls (will only do a TLS handshake, for non-HTTP services like ldaps or imaps)")
+	fmt.Println("  -concurrency=10 (will check up to 10 targets at the same time)")
+	fmt.Println("  -stream (will show results as they finish instead of in the order given)")
+	fmt.Println("  -all-ips (will check every A and AAAA record of the host on its own)")
+	fmt.Println("  -resolve=example.com:443:10.0.0.5 (will connect to 10.0.0.5 but still check the cert for example.com)")
+	fmt.Println("  -connect-to=example.com:443:lb.example.net:8443 (will connect to lb.example.net:8443 instead)")
+	fmt.Println("  -proxy=socks5://127.0.0.1:1080 (will connect through an http:// or socks5:// proxy)")
+	fmt.Println("  -no-proxy (will ignore the HTTPS_PROXY and HTTP_PROXY environment variables)")
+	fmt.Println("  -max-redirects=3 (will follow up to 3 redirects, 0 to not follow any)", " default =", checkssl.DEFAULT_MAX_REDIRECTS)
+	fmt.Println("  -ocsp (will ask the OCSP responder if the certificate has been revoked)")
+	fmt.Println("  -ocsp-responder=http://ocsp.example.com (will send OCSP requests to this responder instead)")
+	fmt.Println("  -crl (will check each certificate against the CRLs it lists)")
+	fmt.Println("  -crl-file=ca.crl (will use this CRL instead of downloading one, can be repeated)")
+	fmt.Println("  -crl-cache=/tmp/crls (will keep downloaded CRLs in this folder until they expire)")
+	fmt.Println("  -ct (will check the certificate has enough valid SCTs for Chrome and Apple)")
+	fmt.Println("  -ct-logs=log_list.json (will use this CT log list instead of the bundled one)")
+	fmt.Println("  -aia (will download intermediates the server did not send from the CA Issuers url, to show which are missing)")
+	fmt.Println("  -ca-file=ca.pem (will trust the CA certificates in this PEM file instead of the system roots)")
+	fmt.Println("  -ca-dir=/etc/ssl/private-ca (will trust the CA certificates in every PEM file in this folder)")
+	fmt.Println("  -ca-append (will add the -ca-file and -ca-dir certificates to the system roots instead of replacing them)")
+	fmt.Println("  -client-cert=client.pem (will send this client certificate when the server asks for one, PEM or PKCS#12)")
+	fmt.Println("  -client-key=client.key (will use this PEM private key with -client-cert)")
+	fmt.Println("  -client-password=secret (will use this password to open a PKCS#12 -client-cert)")
+	fmt.Println("  -min-rsa=2048 (will fail certificates with an RSA key smaller than this many bits)")
+	fmt.Println("  -min-ec=256 (will fail certificates with an EC key smaller than this many bits)")
+	fmt.Println("  -tls-versions (will try a handshake at every TLS version and list the ones the server accepts)")
+	fmt.Println("  -min-tls=1.2 (will fail if the server accepts a TLS version below this one, turns on -tls-versions)")
+	fmt.Println("  -ciphers (will try each cipher suite at every TLS version and list the ones the server accepts, turns on -tls-versions)")
+	fmt.Println("  -legacy (will send hand built ClientHellos for SSL v3.0 through TLS v1.2 with the insecure cipher suites Go can not offer, and list what the server accepts)")
+	fmt.Println("  -simulate (will connect as a set of common clients, like old Android, Java 8, OpenSSL and current browsers, and show what each one negotiates)")
+	fmt.Println("  -client-profiles=clients.json (will add the client profiles in this file to the built in ones, turns on -simulate)")
+	fmt.Println("  -hsts (will check the Strict-Transport-Security header, that http:// redirects to https:// and if the host is on the HSTS preload list)")
+	fmt.Println("  -hsts-min-age=31536000 (will fail if the HSTS header is missing or its max-age is below this many seconds, turns on -hsts)")
+	fmt.Println("  -hsts-preload=preload.json (will use this HSTS preload list instead of the bundled one, turns on -hsts)")
 }